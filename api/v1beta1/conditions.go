@@ -50,7 +50,16 @@ const (
 )
 
 // OpenStack Baremetal Reasons used by API objects.
-const ()
+const (
+	// OpenStackBaremetalSetBmhProvisioningFailedReason - One or more BMHs reported a Metal3 provisioning error or exceeded the provisioning timeout
+	OpenStackBaremetalSetBmhProvisioningFailedReason condition.Reason = "BmhProvisioningFailed"
+
+	// OpenStackBaremetalSetBmhReplacedReason - A BMH that failed to provision was released and is being replaced
+	OpenStackBaremetalSetBmhReplacedReason condition.Reason = "BmhReplaced"
+
+	// OpenStackBaremetalSetBmhProvisioningPausedReason - Hosts are waiting to be provisioned because the set is paused
+	OpenStackBaremetalSetBmhProvisioningPausedReason condition.Reason = "BmhProvisioningPaused"
+)
 
 // Common Messages used by API objects.
 const (
@@ -144,9 +153,21 @@ const (
 	// OpenStackBaremetalSetBmhProvisioningReadyRunningMessage
 	OpenStackBaremetalSetBmhProvisioningReadyRunningMessage = "OpenStackBaremetalSet BMH provisioning in progress"
 
+	// OpenStackBaremetalSetBmhProvisioningReadyWaitingMessage
+	OpenStackBaremetalSetBmhProvisioningReadyWaitingMessage = "OpenStackBaremetalSet BMH provisioning in progress, host(s) waiting for earlier ones to finish: %s"
+
+	// OpenStackBaremetalSetBmhProvisioningReadyPausedMessage
+	OpenStackBaremetalSetBmhProvisioningReadyPausedMessage = "OpenStackBaremetalSet BMH provisioning paused, host(s) waiting: %s"
+
 	// OpenStackBaremetalSetBmhProvisioningReadyErrorMessage
 	OpenStackBaremetalSetBmhProvisioningReadyErrorMessage = "OpenStackBaremetalSet BMH provisioning error occured %s"
 
+	// OpenStackBaremetalSetBmhProvisioningReadyFailedMessage
+	OpenStackBaremetalSetBmhProvisioningReadyFailedMessage = "OpenStackBaremetalSet BMH provisioning failed for host(s): %s"
+
+	// OpenStackBaremetalSetBmhProvisioningReadyReplacingMessage
+	OpenStackBaremetalSetBmhProvisioningReadyReplacingMessage = "OpenStackBaremetalSet BMH provisioning replacing failed BMH(s) for host(s): %s"
+
 	// OpenStackBaremetalSetBmhProvisioningReadyMessage
 	OpenStackBaremetalSetBmhProvisioningReadyMessage = "OpenStackBaremetalSet BMH provisioning completed"
 )
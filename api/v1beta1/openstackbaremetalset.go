@@ -1,13 +1,21 @@
 package v1beta1
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/go-logr/logr"
 	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	goClient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -15,7 +23,15 @@ import (
 const (
 	// ServiceName -
 	ServiceName                    = "openstackbaremetalset"
-	IndividualComputeLabelMismatch = "one or more computes did not match the available Baremetalhosts due to their bmhLabelSelector(s)"
+	IndividualComputeLabelMismatch = "one or more computes did not match the available Baremetalhosts due to their bmhLabelSelector(s) or bmhName"
+	// QuarantineLabel - Label placed on BaremetalHosts released after failing to provision, such BMHs are never selected
+	QuarantineLabel = "baremetal.openstack.org/quarantined"
+	// FailedBmhAnnotation - Annotation placed on BaremetalHosts released after failing to provision, listing the
+	// OpenStackBaremetalSets (namespace/name, comma-separated) that never select them again
+	FailedBmhAnnotation = "baremetal.openstack.org/failed-for"
+	// HostRemovalAnnotation - Annotation placed on BaremetalHosts to allow their removal from a set with the
+	// RequireAnnotation scale-down policy
+	HostRemovalAnnotation = "baremetal.openstack.org/delete-host"
 )
 
 // GetBaremetalHosts - Get all BaremetalHosts in the chosen namespace with (optional) labels
@@ -45,6 +61,55 @@ func GetBaremetalHosts(
 
 }
 
+// GetBaremetalSetBaremetalHosts - Get the BaremetalHosts matching the bmhLabelSelector and bmhMatchExpressions
+// of the OpenStackBaremetalSet
+func GetBaremetalSetBaremetalHosts(
+	ctx context.Context,
+	c goClient.Client,
+	instance *OpenStackBaremetalSet,
+) (*metal3v1.BareMetalHostList, error) {
+	selector, err := instance.Spec.BmhSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	bmhHostsList := &metal3v1.BareMetalHostList{}
+	err = c.List(ctx, bmhHostsList,
+		client.InNamespace(instance.Spec.BmhNamespace),
+		client.MatchingLabelsSelector{Selector: selector},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return bmhHostsList, nil
+}
+
+// BmhSelector - The label selector of the BaremetalHosts the set can use
+func (spec OpenStackBaremetalSetTemplateSpec) BmhSelector() (k8s_labels.Selector, error) {
+	return getBmhSelector(spec.BmhLabelSelector, spec.BmhMatchExpressions)
+}
+
+// BmhSelector - The label selector the BaremetalHost of the host has to match, on top of the set's one
+func (spec InstanceSpec) BmhSelector() (k8s_labels.Selector, error) {
+	return getBmhSelector(spec.BmhLabelSelector, spec.BmhMatchExpressions)
+}
+
+// getBmhSelector - Combine a bmhLabelSelector and bmhMatchExpressions into a label selector, which
+// matches everything if both are empty
+func getBmhSelector(
+	matchLabels map[string]string,
+	matchExpressions []metav1.LabelSelectorRequirement,
+) (k8s_labels.Selector, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
+		MatchLabels:      matchLabels,
+		MatchExpressions: matchExpressions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid BaremetalHost label selector: %w", err)
+	}
+	return selector, nil
+}
+
 // VerifyAndSyncBaremetalStatusBmhRefs - Verify that BMHs haven't been improperly deleted
 // outside of OpenStackBaremetalSet.  If deletions have occurred, we sync the state
 // of instance.Status.BaremetalHosts.
@@ -82,9 +147,11 @@ func VerifyAndSyncBaremetalStatusBmhRefs(
 	return nil
 }
 
-// VerifyBaremetalSetScaleUp -
+// VerifyBaremetalSetScaleUp - Select a BaremetalHost for each new host of the set, preferring them in the
+// order scorer ranks them
 func VerifyBaremetalSetScaleUp(
 	l logr.Logger,
+	scorer BmhScorer,
 	instance *OpenStackBaremetalSet,
 	allBmhs *metal3v1.BareMetalHostList,
 	existingBmhs *metal3v1.BareMetalHostList) (map[string]metal3v1.BareMetalHost, error) {
@@ -93,6 +160,10 @@ func VerifyBaremetalSetScaleUp(
 	newComputes := map[string]InstanceSpec{}
 
 	for hostName, compute := range instance.Spec.BaremetalHosts {
+		// Hosts adopting a BaremetalHost are not allocated one from the free pool
+		if compute.AdoptBmh != "" {
+			continue
+		}
 		// Any host name not found in the instance's status' BaremetalHosts map
 		// is a new compute
 		if _, found := instance.Status.BaremetalHosts[hostName]; !found {
@@ -113,46 +184,37 @@ func VerifyBaremetalSetScaleUp(
 			labelStr = fmt.Sprintf("%v", instance.Spec.BmhLabelSelector)
 			labelStr = strings.Replace(labelStr, "map[", "[", 1)
 		}
-
-		l.Info("Attempting to find BaremetalHosts for scale-up of OpenStackBaremetalSet", "OpenStackBaremetalSet",
-			instance.Name, "namespace", instance.Spec.BmhNamespace, "quantity", newBmhsNeededCount, "labels", labelStr)
-
-		// First find BMHs that match everything WITHOUT considering individual compute host labels
-		for _, baremetalHost := range allBmhs.Items {
-			mismatch := false
-
-			if !verifyBaremetalSetHardwareMatch(l, instance, &baremetalHost) {
-				l.Info("BaremetalHost cannot be used because it does not match hardware requirements", "BMH", baremetalHost.ObjectMeta.Name)
-				mismatch = true
-			}
-
-			if baremetalHost.Status.Provisioning.State != metal3v1.StateAvailable {
-				l.Info("BaremetalHost ProvisioningState is not 'Available'")
-				mismatch = true
-			}
-
-			if baremetalHost.Spec.Image != nil && baremetalHost.Spec.Image.URL != "" {
-				l.Info("BaremetalHost cannot be used because it already has an image", "BMH", baremetalHost.ObjectMeta.Name)
-				mismatch = true
+		if len(instance.Spec.BmhMatchExpressions) > 0 {
+			selector, err := instance.Spec.BmhSelector()
+			if err != nil {
+				return nil, err
 			}
+			labelStr = fmt.Sprintf("[%s]", selector)
+		}
 
-			if baremetalHost.Spec.ExternallyProvisioned {
-				l.Info("BaremetalHost cannot be used because it is externally provisioned", "BMH", baremetalHost.ObjectMeta.Name)
-				mismatch = true
+		// Host selectors are only checked while matching, so reject invalid ones upfront
+		for hostName, compute := range newComputes {
+			if _, err := compute.BmhSelector(); err != nil {
+				return nil, fmt.Errorf("host %s: %w", hostName, err)
 			}
+		}
 
-			if baremetalHost.Spec.CustomDeploy != nil {
-				l.Info("BaremetalHost cannot be used because it already has a customDeploy", "BMH", baremetalHost.ObjectMeta.Name)
-				mismatch = true
-			}
+		// BMHs only need to satisfy the hardware requests of one of the new hosts to be available,
+		// each host is then only matched to BMHs satisfying its own
+		hardwareReqs := baremetalSetHardwareReqs(instance, newComputes)
+		for hostName, compute := range newComputes {
+			merged := instance.Spec.HardwareReqs.Merge(compute.HardwareReqs)
+			compute.HardwareReqs = &merged
+			newComputes[hostName] = compute
+		}
 
-			if baremetalHost.Spec.ConsumerRef != nil {
-				l.Info("BaremetalHost cannot be used because it already has a consumerRef", "BMH", baremetalHost.ObjectMeta.Name)
-				mismatch = true
-			}
+		l.Info("Attempting to find BaremetalHosts for scale-up of OpenStackBaremetalSet", "OpenStackBaremetalSet",
+			instance.Name, "namespace", instance.Spec.BmhNamespace, "quantity", newBmhsNeededCount, "labels", labelStr)
 
+		// First find BMHs that match everything WITHOUT considering individual compute host labels
+		for _, baremetalHost := range allBmhs.Items {
 			// If for any reason we can't use this BMH, do not add to the list of available BMHs
-			if mismatch {
+			if len(baremetalHostRejectionReasons(l, instance, hardwareReqs, &baremetalHost)) > 0 {
 				continue
 			}
 
@@ -161,6 +223,9 @@ func VerifyBaremetalSetScaleUp(
 			availableBaremetalHosts = append(availableBaremetalHosts, baremetalHost)
 		}
 
+		// Candidates are tried in order, so rank them to get the most suitable BMHs allocated
+		sortBaremetalHostsByScore(scorer, instance, availableBaremetalHosts)
+
 		// We only want to continue to individual compute label matching if we actually have
 		// enough BMHs remaining given the filtering above
 		if len(availableBaremetalHosts) >= newBmhsNeededCount {
@@ -201,11 +266,39 @@ func VerifyBaremetalSetScaleUp(
 			//
 			// The function called here accomplishes this (see its definition below for details)...
 
-			selectedBaremetalHosts = findValidBaremetalSetInstanceLabelAssignments(newComputes, availableBaremetalHosts)
+			var unmatchedComputes []string
+			selectedBaremetalHosts, unmatchedComputes = findValidBaremetalSetInstanceLabelAssignments(newComputes, availableBaremetalHosts)
 
 			if len(selectedBaremetalHosts) < 1 {
-				l.Info("Unable to match requested new computes to satisfactory set of BaremetalHosts due to labeling")
-				errIndividualLabelsStr = fmt.Sprintf(": %s", IndividualComputeLabelMismatch)
+				l.Info("Unable to match requested new computes to satisfactory set of BaremetalHosts due to labeling",
+					"unmatched", unmatchedComputes)
+				errIndividualLabelsStr = fmt.Sprintf(": %s (unmatched hosts: %s)",
+					IndividualComputeLabelMismatch, strings.Join(unmatchedComputes, ", "))
+				if pinned := unavailablePinnedBaremetalHosts(newComputes, availableBaremetalHosts); len(pinned) > 0 {
+					errIndividualLabelsStr = fmt.Sprintf("%s (pinned BaremetalHosts not available: %s)",
+						errIndividualLabelsStr, strings.Join(pinned, ", "))
+				}
+			} else if len(instance.Spec.TopologySpreadConstraints) > 0 {
+				// The labels can be satisfied, now look for the assignment that also keeps the hosts spread
+				spread := newTopologySpread(instance.Spec.TopologySpreadConstraints, availableBaremetalHosts, existingBmhs.Items)
+				unsatisfiable := spread.String()
+
+				// The label matching often keeps the hosts spread already, which saves the search
+				var err error
+				if !spread.satisfiedBy(selectedBaremetalHosts) {
+					selectedBaremetalHosts, err = findSpreadBaremetalSetInstanceLabelAssignments(
+						newComputes, availableBaremetalHosts, spread, maxSpreadSearchSteps)
+				}
+
+				if err != nil {
+					l.Info("Gave up matching requested new computes to BaremetalHosts within the topologySpreadConstraints")
+					errIndividualLabelsStr = fmt.Sprintf(": %s for %d new hosts, try adding fewer hosts at once (%s)",
+						err, newBmhsNeededCount, unsatisfiable)
+				} else if len(selectedBaremetalHosts) < 1 {
+					l.Info("Unable to match requested new computes to BaremetalHosts within the topologySpreadConstraints")
+					errIndividualLabelsStr = fmt.Sprintf(": unable to satisfy topologySpreadConstraints for %d new hosts (%s)",
+						newBmhsNeededCount, unsatisfiable)
+				}
 			}
 		}
 	}
@@ -234,59 +327,529 @@ func VerifyBaremetalSetScaleUp(
 	return selectedBaremetalHosts, nil
 }
 
-// VerifyBaremetalSetScaleDown - TODO: not needed at the current moment
+// unavailablePinnedBaremetalHosts - The bmhName of the computes pinned to a BMH that is not available
+func unavailablePinnedBaremetalHosts(computes map[string]InstanceSpec, availableBmhs []metal3v1.BareMetalHost) []string {
+	unavailable := []string{}
+
+	for hostName, compute := range computes {
+		if compute.BmhName == "" {
+			continue
+		}
+		found := false
+		for _, bmh := range availableBmhs {
+			if bmh.Name == compute.BmhName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unavailable = append(unavailable, fmt.Sprintf("%s (host %s)", compute.BmhName, hostName))
+		}
+	}
+	sort.Strings(unavailable)
+
+	return unavailable
+}
+
+// VerifyBaremetalSetPinning - Verify that the BaremetalHosts the hosts of the set are pinned to via bmhName
+// exist, are free and aren't pinned by a host of another set. otherSets can include the set itself, which is
+// skipped
+func VerifyBaremetalSetPinning(
+	instance *OpenStackBaremetalSet,
+	allBmhs *metal3v1.BareMetalHostList,
+	otherSets []OpenStackBaremetalSet,
+) error {
+	pinningHosts := map[string]string{}
+	errs := []string{}
+
+	for hostName, compute := range instance.Spec.BaremetalHosts {
+		if compute.BmhName == "" {
+			continue
+		}
+		if compute.AdoptBmh != "" {
+			errs = append(errs, fmt.Sprintf("host %s cannot set both bmhName and adoptBmh", hostName))
+			continue
+		}
+		if otherHostName, found := pinningHosts[compute.BmhName]; found {
+			errs = append(errs, fmt.Sprintf("BaremetalHost %s is pinned by both %s and %s", compute.BmhName, hostName, otherHostName))
+			continue
+		}
+		pinningHosts[compute.BmhName] = hostName
+
+		// Hosts that already got a BMH keep it
+		if bmhStatus, found := instance.Status.BaremetalHosts[hostName]; found {
+			if bmhStatus.BmhRef != compute.BmhName {
+				errs = append(errs, fmt.Sprintf("host %s cannot be pinned to BaremetalHost %s as it already uses BaremetalHost %s",
+					hostName, compute.BmhName, bmhStatus.BmhRef))
+			}
+			continue
+		}
+
+		var pinnedBmh *metal3v1.BareMetalHost
+		for i := range allBmhs.Items {
+			if allBmhs.Items[i].Name == compute.BmhName {
+				pinnedBmh = &allBmhs.Items[i]
+				break
+			}
+		}
+		if pinnedBmh == nil {
+			errs = append(errs, fmt.Sprintf("BaremetalHost %s pinned by %s not found in namespace %s",
+				compute.BmhName, hostName, instance.Spec.BmhNamespace))
+			continue
+		}
+		if consumerRef := pinnedBmh.Spec.ConsumerRef; consumerRef != nil {
+			errs = append(errs, fmt.Sprintf("BaremetalHost %s pinned by %s is already consumed by %s %s/%s",
+				compute.BmhName, hostName, consumerRef.Kind, consumerRef.Namespace, consumerRef.Name))
+			continue
+		}
+
+		if otherSetHost := baremetalHostOtherSetHost(instance, compute.BmhName, otherSets); otherSetHost != "" {
+			errs = append(errs, fmt.Sprintf("BaremetalHost %s pinned by %s is already pinned by %s",
+				compute.BmhName, hostName, otherSetHost))
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// VerifyBaremetalSetAdoption - Verify that the BaremetalHosts named by the adoptBmh of hosts that were not
+// adopted yet exist and can be adopted, and return them per hostname. A BaremetalHost can't be adopted when
+// another host of the set already uses or pins it, or a host of another set pins or adopts it. otherSets can
+// include the set itself, which is skipped
+func VerifyBaremetalSetAdoption(
+	instance *OpenStackBaremetalSet,
+	allBmhs *metal3v1.BareMetalHostList,
+	otherSets []OpenStackBaremetalSet,
+) (map[string]metal3v1.BareMetalHost, error) {
+	adoptedBmhs := map[string]metal3v1.BareMetalHost{}
+	adoptingHosts := map[string]string{}
+	errs := []string{}
+
+	// The BaremetalHosts the hosts of the set already use or are pinned to
+	usingHosts := map[string]string{}
+	for hostName, bmhStatus := range instance.Status.BaremetalHosts {
+		usingHosts[bmhStatus.BmhRef] = hostName
+	}
+	for hostName, compute := range instance.Spec.BaremetalHosts {
+		if compute.BmhName != "" {
+			usingHosts[compute.BmhName] = hostName
+		}
+	}
+
+	for hostName, compute := range instance.Spec.BaremetalHosts {
+		if compute.AdoptBmh == "" {
+			continue
+		}
+		if otherHostName, found := adoptingHosts[compute.AdoptBmh]; found {
+			errs = append(errs, fmt.Sprintf("BaremetalHost %s is adopted by both %s and %s", compute.AdoptBmh, hostName, otherHostName))
+			continue
+		}
+		adoptingHosts[compute.AdoptBmh] = hostName
+
+		if _, found := instance.Status.BaremetalHosts[hostName]; found {
+			continue
+		}
+
+		if otherHostName, found := usingHosts[compute.AdoptBmh]; found && otherHostName != hostName {
+			errs = append(errs, fmt.Sprintf("BaremetalHost %s cannot be adopted by %s: it is already used by host %s",
+				compute.AdoptBmh, hostName, otherHostName))
+			continue
+		}
+		if otherSetHost := baremetalHostOtherSetHost(instance, compute.AdoptBmh, otherSets); otherSetHost != "" {
+			errs = append(errs, fmt.Sprintf("BaremetalHost %s cannot be adopted by %s: it is already pinned or adopted by %s",
+				compute.AdoptBmh, hostName, otherSetHost))
+			continue
+		}
+
+		found := false
+		for _, bmh := range allBmhs.Items {
+			if bmh.Name != compute.AdoptBmh {
+				continue
+			}
+			found = true
+
+			if reason := baremetalHostAdoptionError(instance, &bmh); reason != "" {
+				errs = append(errs, fmt.Sprintf("BaremetalHost %s cannot be adopted by %s: %s", bmh.Name, hostName, reason))
+			} else {
+				adoptedBmhs[hostName] = bmh
+			}
+			break
+		}
+
+		if !found {
+			errs = append(errs, fmt.Sprintf("BaremetalHost %s to be adopted by %s not found in namespace %s",
+				compute.AdoptBmh, hostName, instance.Spec.BmhNamespace))
+		}
+	}
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return adoptedBmhs, nil
+}
+
+// baremetalHostOtherSetHost - The host of another set in the same BMH namespace that pins or adopts a
+// BaremetalHost, as "host <hostname> of OpenStackBaremetalSet <namespace>/<name>", empty if there is none
+func baremetalHostOtherSetHost(
+	instance *OpenStackBaremetalSet,
+	bmhName string,
+	otherSets []OpenStackBaremetalSet,
+) string {
+	hosts := []string{}
+	for _, otherSet := range otherSets {
+		if (otherSet.Name == instance.Name && otherSet.Namespace == instance.Namespace) ||
+			otherSet.Spec.BmhNamespace != instance.Spec.BmhNamespace {
+			continue
+		}
+		for otherHostName, otherCompute := range otherSet.Spec.BaremetalHosts {
+			if otherCompute.BmhName == bmhName || otherCompute.AdoptBmh == bmhName {
+				hosts = append(hosts, fmt.Sprintf("host %s of OpenStackBaremetalSet %s/%s",
+					otherHostName, otherSet.Namespace, otherSet.Name))
+			}
+		}
+	}
+	if len(hosts) == 0 {
+		return ""
+	}
+	sort.Strings(hosts)
+	return hosts[0]
+}
+
+// baremetalHostAdoptionError - Why a BaremetalHost can't be adopted by the set, empty if it can
+func baremetalHostAdoptionError(
+	instance *OpenStackBaremetalSet,
+	bmh *metal3v1.BareMetalHost,
+) string {
+	if bmh.Status.Provisioning.State != metal3v1.StateProvisioned &&
+		bmh.Status.Provisioning.State != metal3v1.StateExternallyProvisioned {
+		return fmt.Sprintf("it is neither provisioned nor externally provisioned (state %q)", bmh.Status.Provisioning.State)
+	}
+
+	consumerRef := bmh.Spec.ConsumerRef
+	if consumerRef != nil && (consumerRef.Name != instance.Name || consumerRef.Namespace != instance.Namespace) {
+		return fmt.Sprintf("it is already consumed by %s %s/%s", consumerRef.Kind, consumerRef.Namespace, consumerRef.Name)
+	}
+
+	return ""
+}
+
+// GetBaremetalSetCapacity - Report which of the BMHs matching the bmhLabelSelector of the
+// OpenStackBaremetalSet could still be allocated to new hosts, and why the others cannot.
+// BMHs already consumed by the set itself are left out.
+func GetBaremetalSetCapacity(
+	instance *OpenStackBaremetalSet,
+	allBmhs *metal3v1.BareMetalHostList,
+) *CapacityStatus {
+	capacity := &CapacityStatus{}
+	hardwareReqs := baremetalSetHardwareReqs(instance, instance.Spec.BaremetalHosts)
+
+	for _, baremetalHost := range allBmhs.Items {
+		consumerRef := baremetalHost.Spec.ConsumerRef
+		if consumerRef != nil && consumerRef.Name == instance.Name && consumerRef.Namespace == instance.Namespace {
+			continue
+		}
+
+		// Capacity is refreshed on every reconcile, so don't log why each BMH was rejected
+		reasons := baremetalHostRejectionReasons(logr.Discard(), instance, hardwareReqs, &baremetalHost)
+		if len(reasons) > 0 {
+			capacity.Rejected = append(capacity.Rejected, BmhRejection{
+				Name:    baremetalHost.Name,
+				Reasons: reasons,
+			})
+			continue
+		}
+
+		capacity.Candidates = append(capacity.Candidates, baremetalHost.Name)
+	}
+
+	sort.Strings(capacity.Candidates)
+	sort.Slice(capacity.Rejected, func(i, j int) bool {
+		return capacity.Rejected[i].Name < capacity.Rejected[j].Name
+	})
+	capacity.FreeBmhs = len(capacity.Candidates)
+
+	return capacity
+}
+
+// baremetalSetHardwareReqs - The distinct hardware requests of the hosts once merged with those of the set,
+// or only those of the set if there are no hosts
+func baremetalSetHardwareReqs(instance *OpenStackBaremetalSet, computes map[string]InstanceSpec) []HardwareReqs {
+	hardwareReqs := []HardwareReqs{}
+	seen := map[HardwareReqs]bool{}
+
+	for _, hostName := range slices.Sorted(maps.Keys(computes)) {
+		merged := instance.Spec.HardwareReqs.Merge(computes[hostName].HardwareReqs)
+		if !seen[merged] {
+			seen[merged] = true
+			hardwareReqs = append(hardwareReqs, merged)
+		}
+	}
+	if len(hardwareReqs) == 0 {
+		hardwareReqs = append(hardwareReqs, instance.Spec.HardwareReqs)
+	}
+
+	return hardwareReqs
+}
+
+// baremetalHostRejectionReasons - All the reasons, if any, why a BMH can't be allocated to a new host of the set.
+// A BMH satisfying any of hardwareReqs is not rejected for its hardware
+func baremetalHostRejectionReasons(
+	l logr.Logger,
+	instance *OpenStackBaremetalSet,
+	hardwareReqs []HardwareReqs,
+	baremetalHost *metal3v1.BareMetalHost,
+) []BmhRejectionReason {
+	reasons := []BmhRejectionReason{}
+
+	if !slices.ContainsFunc(hardwareReqs, func(reqs HardwareReqs) bool {
+		return verifyBaremetalSetHardwareMatch(l, reqs, baremetalHost)
+	}) {
+		l.Info("BaremetalHost cannot be used because it does not match hardware requirements", "BMH", baremetalHost.ObjectMeta.Name)
+		reasons = append(reasons, BmhRejectionHardwareMismatch)
+	}
+
+	if baremetalHost.Status.Provisioning.State != metal3v1.StateAvailable {
+		l.Info("BaremetalHost ProvisioningState is not 'Available'")
+		reasons = append(reasons, BmhRejectionNotAvailable)
+	}
+
+	if baremetalHost.Spec.Image != nil && baremetalHost.Spec.Image.URL != "" {
+		l.Info("BaremetalHost cannot be used because it already has an image", "BMH", baremetalHost.ObjectMeta.Name)
+		reasons = append(reasons, BmhRejectionHasImage)
+	}
+
+	if baremetalHost.Spec.ExternallyProvisioned {
+		l.Info("BaremetalHost cannot be used because it is externally provisioned", "BMH", baremetalHost.ObjectMeta.Name)
+		reasons = append(reasons, BmhRejectionExternallyProvisioned)
+	}
+
+	if baremetalHost.Spec.CustomDeploy != nil {
+		l.Info("BaremetalHost cannot be used because it already has a customDeploy", "BMH", baremetalHost.ObjectMeta.Name)
+		reasons = append(reasons, BmhRejectionHasCustomDeploy)
+	}
+
+	if baremetalHost.Spec.ConsumerRef != nil {
+		l.Info("BaremetalHost cannot be used because it already has a consumerRef", "BMH", baremetalHost.ObjectMeta.Name)
+		reasons = append(reasons, BmhRejectionConsumed)
+	}
+
+	if _, ok := baremetalHost.Labels[QuarantineLabel]; ok {
+		l.Info("BaremetalHost cannot be used because it is quarantined", "BMH", baremetalHost.ObjectMeta.Name)
+		reasons = append(reasons, BmhRejectionQuarantined)
+	}
+
+	if BaremetalHostFailedFor(instance, baremetalHost) {
+		l.Info("BaremetalHost cannot be used because it already failed to provision for the set", "BMH", baremetalHost.ObjectMeta.Name)
+		reasons = append(reasons, BmhRejectionFailed)
+	}
+
+	for _, constraint := range instance.Spec.TopologySpreadConstraints {
+		if _, ok := baremetalHost.Labels[constraint.TopologyKey]; !ok {
+			l.Info("BaremetalHost cannot be used because it lacks a topology label", "BMH", baremetalHost.ObjectMeta.Name,
+				"topologyKey", constraint.TopologyKey)
+			reasons = append(reasons, BmhRejectionMissingTopologyLabel)
+			break
+		}
+	}
+
+	return reasons
+}
+
+// BaremetalHostFailedFor - Whether the BaremetalHost was released by the set after failing to provision
+func BaremetalHostFailedFor(instance *OpenStackBaremetalSet, baremetalHost *metal3v1.BareMetalHost) bool {
+	failedFor := baremetalHost.Annotations[FailedBmhAnnotation]
+	return failedFor != "" && slices.Contains(strings.Split(failedFor, ","), instance.Namespace+"/"+instance.Name)
+}
+
+// MarkBaremetalHostFailed - Add the set to the FailedBmhAnnotation of the BaremetalHost, so that it never
+// selects it again
+func MarkBaremetalHostFailed(instance *OpenStackBaremetalSet, baremetalHost *metal3v1.BareMetalHost) {
+	if BaremetalHostFailedFor(instance, baremetalHost) {
+		return
+	}
+	annotations := baremetalHost.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	failedFor := instance.Namespace + "/" + instance.Name
+	if annotations[FailedBmhAnnotation] != "" {
+		failedFor = annotations[FailedBmhAnnotation] + "," + failedFor
+	}
+	annotations[FailedBmhAnnotation] = failedFor
+	baremetalHost.SetAnnotations(annotations)
+}
+
+// VerifyBaremetalSetScaleDown - With the RequireAnnotation scale-down policy, verify that the BMH of every
+// host removed from spec.baremetalHosts is annotated for deletion. Hosts that have no BMH allocated yet can
+// always be removed
 func VerifyBaremetalSetScaleDown(
 	instance *OpenStackBaremetalSet,
-	existingBmhs *metal3v1.BareMetalHostList,
-	removalAnnotatedBmhCount int) error {
-	// How many new BaremetalHost de-allocations do we need (if any)?
-	bmhsToRemoveCount := len(existingBmhs.Items) - len(instance.Spec.BaremetalHosts)
+	existingBmhs *metal3v1.BareMetalHostList) error {
+	if instance.Spec.ScaleDownPolicy != ScaleDownPolicyRequireAnnotation {
+		return nil
+	}
+
+	notAnnotated := []string{}
+
+	for hostName, bmhStatus := range instance.Status.BaremetalHosts {
+		if _, found := instance.Spec.BaremetalHosts[hostName]; found {
+			continue
+		}
+
+		for _, bmh := range existingBmhs.Items {
+			if bmh.Name != bmhStatus.BmhRef {
+				continue
+			}
+			if _, annotated := bmh.Annotations[HostRemovalAnnotation]; !annotated {
+				notAnnotated = append(notAnnotated, fmt.Sprintf("%s (BaremetalHost %s)", hostName, bmh.Name))
+			}
+			break
+		}
+	}
 
-	if bmhsToRemoveCount > removalAnnotatedBmhCount {
-		return fmt.Errorf("unable to find sufficient amount of BaremetalHosts annotated for scale-down (%d found, %d requested)",
-			removalAnnotatedBmhCount,
-			bmhsToRemoveCount)
+	if len(notAnnotated) > 0 {
+		sort.Strings(notAnnotated)
+		return fmt.Errorf("unable to remove host(s) %s from spec.baremetalHosts: scaleDownPolicy is %s and their BaremetalHosts are not annotated with %s",
+			strings.Join(notAnnotated, ", "),
+			ScaleDownPolicyRequireAnnotation,
+			HostRemovalAnnotation)
 	}
 
 	return nil
 }
 
-// Function to find valid assignments for computes-to-BMHs, given their labels, using backtracking
-func findValidBaremetalSetInstanceLabelAssignments(computes map[string]InstanceSpec, bmhs []metal3v1.BareMetalHost) map[string]metal3v1.BareMetalHost {
-	// First create map of valid computes-to-BMHs possibilities
-	computesToBmhs := map[string][]metal3v1.BareMetalHost{}
+// baremetalSetInstanceCandidates - The indexes in bmhs of the BMHs each compute can be assigned given its
+// labels, bmhName and hardwareReqs, in order, along with the computes ordered by their number of candidates,
+// then name. The hardwareReqs of the computes are expected to be already merged with those of the set
+func baremetalSetInstanceCandidates(
+	computes map[string]InstanceSpec,
+	bmhs []metal3v1.BareMetalHost,
+) (map[string][]int, []string) {
+	// Computes mostly share the same hardwareReqs, so only check each BMH once against each of them
+	hardwareMatches := map[HardwareReqs][]bool{}
+	matchesHardware := func(reqs *HardwareReqs, i int) bool {
+		if reqs == nil {
+			return true
+		}
+		if _, found := hardwareMatches[*reqs]; !found {
+			matches := make([]bool, len(bmhs))
+			for j := range bmhs {
+				matches[j] = verifyBaremetalSetHardwareMatch(logr.Discard(), *reqs, &bmhs[j])
+			}
+			hardwareMatches[*reqs] = matches
+		}
+		return hardwareMatches[*reqs][i]
+	}
+
+	computesToBmhs := map[string][]int{}
 	for compName, comp := range computes {
-		for _, bmh := range bmhs {
-			if IsMapSubset(bmh.GetLabels(), comp.BmhLabelSelector) {
-				computesToBmhs[compName] = append(computesToBmhs[compName], bmh)
+		// Selectors were validated by the caller, a broken one simply matches nothing
+		selector, err := comp.BmhSelector()
+		if err != nil {
+			continue
+		}
+		for i := range bmhs {
+			// A pinned compute can only ever get its own BMH
+			if comp.BmhName != "" && comp.BmhName != bmhs[i].Name {
+				continue
+			}
+			if selector.Matches(k8s_labels.Set(bmhs[i].GetLabels())) && matchesHardware(comp.HardwareReqs, i) {
+				computesToBmhs[compName] = append(computesToBmhs[compName], i)
 			}
 		}
 	}
 
-	// Now sort computes by the number of valid matches, as sorting helps in
-	// cases with large numbers of computes and/or BMHs, for a more optimal
-	// backtracking
+	// The most constrained computes pick first, which keeps the greedy picks of the
+	// matching and the backtracking below close to the final assignment
+	computeArray := make([]string, 0, len(computes))
+	for compName := range computes {
+		computeArray = append(computeArray, compName)
+	}
+	sort.Slice(computeArray, func(i, j int) bool {
+		if len(computesToBmhs[computeArray[i]]) != len(computesToBmhs[computeArray[j]]) {
+			return len(computesToBmhs[computeArray[i]]) < len(computesToBmhs[computeArray[j]])
+		}
+		return computeArray[i] < computeArray[j]
+	})
 
-	// Make array of compute host names for use in "backtrack" func
-	computeArray := make([]string, len(computes))
+	return computesToBmhs, computeArray
+}
 
-	i := 0
-	// The keys of the "computes" map will be traversed in this for loo[] in random
-	// order, but that does not matter, as we are defining the order of keys in
-	// this array that we are creating and we will adhere to that from here on out
-	for k := range computes {
-		computeArray[i] = k
-		i++
+// Function to find valid assignments for computes-to-BMHs, given their labels, as a maximum bipartite
+// matching of the computes to the BMHs. Every compute gets the first of its candidate BMHs that is still
+// free, in the order of bmhs, unless another compute needs it for all computes to be assigned. Returns
+// nil and the computes left unassigned, sorted, if not all computes can be assigned
+func findValidBaremetalSetInstanceLabelAssignments(
+	computes map[string]InstanceSpec,
+	bmhs []metal3v1.BareMetalHost,
+) (map[string]metal3v1.BareMetalHost, []string) {
+	computesToBmhs, computeArray := baremetalSetInstanceCandidates(computes, bmhs)
+
+	adjacency := make([][]int, len(computeArray))
+	for i, compName := range computeArray {
+		adjacency[i] = computesToBmhs[compName]
 	}
 
-	// Now we can do the actual sorting by number of preliminary matches
-	sort.Slice(computeArray[:], func(i, j int) bool {
-		return len(computesToBmhs[computeArray[i]]) < len(computesToBmhs[computeArray[j]])
-	})
+	matching := maximumBipartiteMatching(adjacency, len(bmhs))
+
+	assignment := map[string]metal3v1.BareMetalHost{}
+	unmatched := []string{}
+	for i, compName := range computeArray {
+		if matching[i] < 0 {
+			unmatched = append(unmatched, compName)
+			continue
+		}
+		assignment[compName] = bmhs[matching[i]]
+	}
+
+	if len(unmatched) > 0 {
+		sort.Strings(unmatched)
+		return nil, unmatched
+	}
+	return assignment, nil
+}
+
+// maxSpreadSearchSteps - How many candidate BMHs findSpreadBaremetalSetInstanceLabelAssignments looks at
+// before giving up, which keeps the webhook and reconciles well within their timeouts
+const maxSpreadSearchSteps = 1_000_000
+
+// errSpreadSearchExhausted - findSpreadBaremetalSetInstanceLabelAssignments gave up before finding an assignment
+// or proving there is none
+var errSpreadSearchExhausted = errors.New("gave up looking for an assignment within the topologySpreadConstraints")
+
+// Function to find valid assignments for computes-to-BMHs, given their labels, that also keep the hosts
+// within the maxSkew of a topology spread, using backtracking. BMHs in the least populated domains are
+// tried first. Only worth calling once findValidBaremetalSetInstanceLabelAssignments found the labels can
+// be satisfied, as the spread can't be checked before all computes are assigned. Returns nil if there is
+// no such assignment, and errSpreadSearchExhausted if it wasn't found after looking at maxSteps candidates
+func findSpreadBaremetalSetInstanceLabelAssignments(
+	computes map[string]InstanceSpec,
+	bmhs []metal3v1.BareMetalHost,
+	spread *topologySpread,
+	maxSteps int,
+) (map[string]metal3v1.BareMetalHost, error) {
+	computesToBmhs, computeArray := baremetalSetInstanceCandidates(computes, bmhs)
+	classes := baremetalHostClasses(computes, bmhs)
 
 	// Finally create and use a backtracking func to find valid assignments
 	assignedBMHs := map[string]bool{}                 // Keep track of assigned BMHs
 	assignment := map[string]metal3v1.BareMetalHost{} // Store the final assignments
+	steps := 0
+
+	// BMHs pinned by a compute can't be swapped for another BMH with the same labels
+	pinnedBMHs := map[string]bool{}
+	for _, comp := range computes {
+		if comp.BmhName != "" {
+			pinnedBMHs[comp.BmhName] = true
+		}
+	}
 
 	// The backtracking function that we will use to crawl all potential
 	// compute-to-BMH assignments, using the initial matching map that we
@@ -295,15 +858,35 @@ func findValidBaremetalSetInstanceLabelAssignments(computes map[string]InstanceS
 
 	backtrack = func(index int) bool {
 		if index == len(computes) {
-			return true // All computes are assigned
+			// All computes are assigned
+			return spread.satisfied()
+		}
+		if steps > maxSteps || !spread.feasible(len(computes)-index) {
+			return false
 		}
 
 		comp := computeArray[index]
-		for _, bmh := range computesToBmhs[comp] {
+		candidates := spread.leastPopulatedFirst(bmhs, computesToBmhs[comp])
+		steps += len(candidates)
+
+		// Unpinned BMHs with the same labels that satisfy the same hardwareReqs are interchangeable, so if
+		// one of them didn't work for this compute, none of the others will either
+		triedClasses := map[string]bool{}
+
+		for _, i := range candidates {
+			bmh := &bmhs[i]
 			if !assignedBMHs[bmh.Name] {
+				if !pinnedBMHs[bmh.Name] {
+					if triedClasses[classes[bmh.Name]] {
+						continue
+					}
+					triedClasses[classes[bmh.Name]] = true
+				}
+
 				// Assign this BMH to the compute host
-				assignment[comp] = bmh
+				assignment[comp] = *bmh
 				assignedBMHs[bmh.Name] = true
+				spread.add(bmh, 1)
 
 				// Recur to assign the next compute
 				if backtrack(index + 1) {
@@ -313,6 +896,11 @@ func findValidBaremetalSetInstanceLabelAssignments(computes map[string]InstanceS
 				// If this assignment didn't work, backtrack
 				delete(assignment, comp)
 				assignedBMHs[bmh.Name] = false
+				spread.add(bmh, -1)
+
+				if steps > maxSteps {
+					return false
+				}
 			}
 		}
 		return false
@@ -322,10 +910,48 @@ func findValidBaremetalSetInstanceLabelAssignments(computes map[string]InstanceS
 	// for the next compute with the consumed BMHs removed from consideration -- backtracking down the
 	// stack whenever matches are needed but exhausted for that particular path
 	if backtrack(0) {
-		return assignment // Return the valid assignments we have chosen
-	} else {
-		return nil // No valid assignments found to satisify all computes' bmhLabelSelectors
+		return assignment, nil // Return the valid assignments we have chosen
+	}
+	if steps > maxSteps {
+		return nil, errSpreadSearchExhausted
+	}
+	return nil, nil // No valid assignments found within the topology spread
+}
+
+// baremetalHostClasses - For each BMH by name, a key identifying its labels and which of the distinct
+// hardwareReqs of the computes it satisfies, so that BMHs of the same class are interchangeable for the
+// computes. The hardwareReqs of the computes are expected to be already merged with those of the set
+func baremetalHostClasses(
+	computes map[string]InstanceSpec,
+	bmhs []metal3v1.BareMetalHost,
+) map[string]string {
+	seen := map[HardwareReqs]bool{}
+	distinctReqs := []HardwareReqs{}
+	for _, compName := range slices.Sorted(maps.Keys(computes)) {
+		reqs := computes[compName].HardwareReqs
+		if reqs != nil && !seen[*reqs] {
+			seen[*reqs] = true
+			distinctReqs = append(distinctReqs, *reqs)
+		}
+	}
+
+	classes := make(map[string]string, len(bmhs))
+	for i := range bmhs {
+		var class strings.Builder
+		for _, reqs := range distinctReqs {
+			if verifyBaremetalSetHardwareMatch(logr.Discard(), reqs, &bmhs[i]) {
+				class.WriteByte('1')
+			} else {
+				class.WriteByte('0')
+			}
+		}
+		labels := bmhs[i].GetLabels()
+		for _, key := range slices.Sorted(maps.Keys(labels)) {
+			fmt.Fprintf(&class, "\x00%s=%s", key, labels[key])
+		}
+		classes[bmhs[i].Name] = class.String()
 	}
+	return classes
 }
 
 func IsMapSubset[K, V comparable](m map[K]V, sub map[K]V) bool {
@@ -345,11 +971,11 @@ func IsMapSubset[K, V comparable](m map[K]V, sub map[K]V) bool {
 
 func verifyBaremetalSetHardwareMatch(
 	l logr.Logger,
-	instance *OpenStackBaremetalSet,
+	hardwareReqs HardwareReqs,
 	bmh *metal3v1.BareMetalHost,
 ) bool {
 	// If no requested hardware requirements, we're all set
-	if instance.Spec.HardwareReqs == (HardwareReqs{}) {
+	if hardwareReqs == (HardwareReqs{}) {
 		return true
 	}
 
@@ -359,7 +985,7 @@ func verifyBaremetalSetHardwareMatch(
 		return false
 	}
 
-	cpuReqs := instance.Spec.HardwareReqs.CPUReqs
+	cpuReqs := hardwareReqs.CPUReqs
 
 	// CPU architecture is always exact-match only
 	if cpuReqs.Arch != "" && bmh.Status.HardwareDetails.CPU.Arch != cpuReqs.Arch {
@@ -405,7 +1031,7 @@ func verifyBaremetalSetHardwareMatch(
 		}
 	}
 
-	memReqs := instance.Spec.HardwareReqs.MemReqs
+	memReqs := hardwareReqs.MemReqs
 
 	// Memory GBs can be exact-match or (default) greater
 	if memReqs.GbReq.Gb != 0 {
@@ -425,63 +1051,279 @@ func verifyBaremetalSetHardwareMatch(
 		}
 	}
 
-	diskReqs := instance.Spec.HardwareReqs.DiskReqs
-
-	var foundDisk *metal3v1.Storage
-
-	if diskReqs.GbReq.Gb != 0 {
-		diskGbBms := float64(diskReqs.GbReq.Gb)
-		// TODO: Make sure there's at least one disk of this size?
-		for _, disk := range bmh.Status.HardwareDetails.Storage {
-			diskGbBmh := float64(disk.SizeBytes) / float64(1073741824)
+	diskReqs := hardwareReqs.DiskReqs
+	diskGbBms := float64(diskReqs.GbReq.Gb)
 
-			if diskGbBmh == diskGbBms || (!diskReqs.GbReq.ExactMatch && diskGbBmh > diskGbBms) {
-				foundDisk = &disk
-				break
+	if diskReqs != (DiskReqs{}) {
+		matchingDisks := 0
+		for i := range bmh.Status.HardwareDetails.Storage {
+			if diskMatchesReqs(diskReqs, &bmh.Status.HardwareDetails.Storage[i]) {
+				matchingDisks++
 			}
 		}
 
-		if foundDisk == nil {
-			l.Info("BaremetalHost does not contain a disk of proper size that matches request",
+		if !countMatches(matchingDisks, diskReqs.CountReq.Count, diskReqs.CountReq.ExactMatch) {
+			l.Info("BaremetalHost does not contain the number of disks that match request",
 				"BMH",
 				bmh.Name,
+				"Matching disks",
+				matchingDisks,
+				"Disk count request",
+				max(diskReqs.CountReq.Count, 1),
 				"Disk size request",
-				diskGbBms)
+				diskGbBms,
+				"Rotational disk wanted",
+				!diskReqs.SSDReq.SSD)
 
 			return false
 		}
 	}
 
-	// We only care about the SSD flag if the user requested an exact match for it or if SSD is true
-	if diskReqs.SSDReq.ExactMatch || diskReqs.SSDReq.SSD {
-		found := false
+	nicReqs := hardwareReqs.NICReqs
 
-		// If we matched on a disk for a GbReqs above, we need to match on the same disk
-		if foundDisk != nil {
-			if foundDisk.Rotational != diskReqs.SSDReq.SSD {
-				found = true
-			}
-		} else {
-			// TODO: Just need to match on any disk?
-			for _, disk := range bmh.Status.HardwareDetails.Storage {
-				if disk.Rotational != diskReqs.SSDReq.SSD {
-					found = true
-				}
+	if nicReqs != (NICReqs{}) {
+		matchingNICs := 0
+		for _, nic := range bmh.Status.HardwareDetails.NIC {
+			// NIC speed can be exact-match or (default) greater
+			if nicReqs.SpeedReq.Gbps != 0 && nic.SpeedGbps != nicReqs.SpeedReq.Gbps &&
+				(nicReqs.SpeedReq.ExactMatch || nicReqs.SpeedReq.Gbps > nic.SpeedGbps) {
+				continue
 			}
+			matchingNICs++
 		}
 
-		if !found {
-			l.Info("BaremetalHost does not contain a disk that matches 'is rotational' request",
+		if !countMatches(matchingNICs, nicReqs.CountReq.Count, nicReqs.CountReq.ExactMatch) {
+			l.Info("BaremetalHost does not contain the number of NICs that match request",
 				"BMH",
 				bmh.Name,
-				"Rotational disk wanted",
-				diskReqs.SSDReq.SSD)
+				"Matching NICs",
+				matchingNICs,
+				"NIC count request",
+				max(nicReqs.CountReq.Count, 1),
+				"NIC speed request",
+				nicReqs.SpeedReq.Gbps)
 
 			return false
 		}
 	}
 
+	systemReqs := hardwareReqs.SystemReqs
+	systemVendor := bmh.Status.HardwareDetails.SystemVendor
+
+	// System manufacturer and product are always exact-match only
+	if (systemReqs.Manufacturer != "" && systemVendor.Manufacturer != systemReqs.Manufacturer) ||
+		(systemReqs.ProductName != "" && systemVendor.ProductName != systemReqs.ProductName) {
+		l.Info("BaremetalHost system vendor does not match request",
+			"BMH",
+			bmh.Name,
+			"Manufacturer",
+			systemVendor.Manufacturer,
+			"Product name",
+			systemVendor.ProductName,
+			"Manufacturer request",
+			systemReqs.Manufacturer,
+			"Product name request",
+			systemReqs.ProductName)
+
+		return false
+	}
+
+	firmwareReqs := hardwareReqs.FirmwareReqs
+	biosVersion := bmh.Status.HardwareDetails.Firmware.BIOS.Version
+
+	// An unknown BIOS version can't be proven recent enough
+	if firmwareReqs.MinBIOSVersion != "" &&
+		(biosVersion == "" || compareVersions(biosVersion, firmwareReqs.MinBIOSVersion) < 0) {
+		l.Info("BaremetalHost BIOS version is older than requested",
+			"BMH",
+			bmh.Name,
+			"BIOS version",
+			biosVersion,
+			"Minimum BIOS version request",
+			firmwareReqs.MinBIOSVersion)
+
+		return false
+	}
+
 	l.Info("BaremetalHost satisfies hardware requirements", "BMH", bmh.Name)
 
 	return true
 }
+
+// Merge - The hardware requests with those set in overrides replacing their counterparts, e.g. a
+// diskReqs.gbReq override replaces diskReqs.gbReq but keeps diskReqs.ssdReq
+func (r HardwareReqs) Merge(overrides *HardwareReqs) HardwareReqs {
+	if overrides == nil {
+		return r
+	}
+
+	mergeReq(&r.CPUReqs.Arch, overrides.CPUReqs.Arch)
+	mergeReq(&r.CPUReqs.CountReq, overrides.CPUReqs.CountReq)
+	mergeReq(&r.CPUReqs.MhzReq, overrides.CPUReqs.MhzReq)
+	mergeReq(&r.MemReqs.GbReq, overrides.MemReqs.GbReq)
+	mergeReq(&r.DiskReqs.GbReq, overrides.DiskReqs.GbReq)
+	mergeReq(&r.DiskReqs.SSDReq, overrides.DiskReqs.SSDReq)
+	mergeReq(&r.DiskReqs.NVMeReq, overrides.DiskReqs.NVMeReq)
+	mergeReq(&r.DiskReqs.CountReq, overrides.DiskReqs.CountReq)
+	mergeReq(&r.NICReqs.CountReq, overrides.NICReqs.CountReq)
+	mergeReq(&r.NICReqs.SpeedReq, overrides.NICReqs.SpeedReq)
+	mergeReq(&r.SystemReqs.Manufacturer, overrides.SystemReqs.Manufacturer)
+	mergeReq(&r.SystemReqs.ProductName, overrides.SystemReqs.ProductName)
+	mergeReq(&r.FirmwareReqs.MinBIOSVersion, overrides.FirmwareReqs.MinBIOSVersion)
+
+	return r
+}
+
+// mergeReq - Replace a single hardware request with its override, if that one is set
+func mergeReq[T comparable](req *T, override T) {
+	var unset T
+	if override != unset {
+		*req = override
+	}
+}
+
+// diskMatchesReqs - Whether a single disk satisfies every disk request. We only care about the SSD flag
+// if the user requested an exact match for it or if SSD is true. The NVMe request is only a preference,
+// see hasPreferredDisks
+func diskMatchesReqs(diskReqs DiskReqs, disk *metal3v1.Storage) bool {
+	if diskReqs.GbReq.Gb != 0 {
+		diskGbBms := float64(diskReqs.GbReq.Gb)
+		diskGbBmh := float64(disk.SizeBytes) / float64(1073741824)
+		if diskGbBmh != diskGbBms && (diskReqs.GbReq.ExactMatch || diskGbBms > diskGbBmh) {
+			return false
+		}
+	}
+	if (diskReqs.SSDReq.ExactMatch || diskReqs.SSDReq.SSD) && disk.Rotational == diskReqs.SSDReq.SSD {
+		return false
+	}
+	return true
+}
+
+// diskIsPreferred - Whether a disk satisfying the diskReqs is also of the preferred type
+func diskIsPreferred(diskReqs DiskReqs, disk *metal3v1.Storage) bool {
+	return !diskReqs.NVMeReq.NVMe || disk.Type == metal3v1.NVME
+}
+
+// hasPreferredDisks - Whether enough of the disks of the BaremetalHost satisfying the diskReqs are also of the
+// preferred type to satisfy the disk count request
+func hasPreferredDisks(diskReqs DiskReqs, bmh *metal3v1.BareMetalHost) bool {
+	if bmh.Status.HardwareDetails == nil {
+		return false
+	}
+	preferredDisks := 0
+	for i := range bmh.Status.HardwareDetails.Storage {
+		disk := &bmh.Status.HardwareDetails.Storage[i]
+		if diskMatchesReqs(diskReqs, disk) && diskIsPreferred(diskReqs, disk) {
+			preferredDisks++
+		}
+	}
+	return countMatches(preferredDisks, diskReqs.CountReq.Count, diskReqs.CountReq.ExactMatch)
+}
+
+// BaremetalHostRootDeviceHints - The rootDeviceHints of the BaremetalHost of a host of the set: those of the
+// host, else those of the set, else those derived from the disk satisfying the diskReqs of the host as per
+// rootDeviceHintsFrom. nil when there are none
+func BaremetalHostRootDeviceHints(
+	instance *OpenStackBaremetalSet,
+	hostName string,
+	bmh *metal3v1.BareMetalHost,
+) *metal3v1.RootDeviceHints {
+	compute := instance.Spec.BaremetalHosts[hostName]
+	if compute.RootDeviceHints != nil {
+		return compute.RootDeviceHints.DeepCopy()
+	}
+	if instance.Spec.RootDeviceHints != nil {
+		return instance.Spec.RootDeviceHints.DeepCopy()
+	}
+
+	diskReqs := instance.Spec.HardwareReqs.Merge(compute.HardwareReqs).DiskReqs
+	if instance.Spec.RootDeviceHintsFrom == "" || diskReqs == (DiskReqs{}) || bmh.Status.HardwareDetails == nil {
+		return nil
+	}
+
+	// The first disk of the preferred type, else the first disk satisfying the diskReqs
+	var disk *metal3v1.Storage
+	for i := range bmh.Status.HardwareDetails.Storage {
+		candidate := &bmh.Status.HardwareDetails.Storage[i]
+		if !diskMatchesReqs(diskReqs, candidate) {
+			continue
+		}
+		if diskIsPreferred(diskReqs, candidate) {
+			disk = candidate
+			break
+		}
+		if disk == nil {
+			disk = candidate
+		}
+	}
+
+	if disk == nil {
+		return nil
+	}
+
+	switch instance.Spec.RootDeviceHintsFrom {
+	case RootDeviceHintsFromWWN:
+		if disk.WWN != "" {
+			return &metal3v1.RootDeviceHints{WWN: disk.WWN}
+		}
+		fallthrough
+	case RootDeviceHintsFromSerialNumber:
+		if disk.SerialNumber != "" {
+			return &metal3v1.RootDeviceHints{SerialNumber: disk.SerialNumber}
+		}
+	}
+	// The disk can't be told apart from the others
+	return nil
+}
+
+// countMatches - Whether count satisfies a count request, which can be exact-match or (default) greater.
+// An unset request asks for at least one
+func countMatches(count int, request int, exactMatch bool) bool {
+	if request == 0 {
+		return count > 0
+	}
+	return count == request || (!exactMatch && count > request)
+}
+
+// compareVersions - Compare two version strings, e.g. firmware versions, returning -1, 0 or 1. Runs of digits
+// are compared as numbers, anything else as strings
+func compareVersions(a string, b string) int {
+	chunksA, chunksB := versionChunks(a), versionChunks(b)
+	for i := 0; i < len(chunksA) && i < len(chunksB); i++ {
+		numA, errA := strconv.ParseUint(chunksA[i], 10, 64)
+		numB, errB := strconv.ParseUint(chunksB[i], 10, 64)
+		if errA == nil && errB == nil {
+			if numA != numB {
+				return cmp.Compare(numA, numB)
+			}
+			continue
+		}
+		if c := strings.Compare(chunksA[i], chunksB[i]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(chunksA), len(chunksB))
+}
+
+// versionChunks - Split a version into runs of digits and runs of letters, dropping separators,
+// e.g. "U46 v2.10" becomes [U 46 v 2 10]
+func versionChunks(version string) []string {
+	chunks := []string{}
+	start := -1
+	isDigit := false
+	for i, r := range version {
+		alnum := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if start >= 0 && (!alnum || unicode.IsDigit(r) != isDigit) {
+			chunks = append(chunks, version[start:i])
+			start = -1
+		}
+		if alnum && start < 0 {
+			start = i
+			isDigit = unicode.IsDigit(r)
+		}
+	}
+	if start >= 0 {
+		chunks = append(chunks, version[start:])
+	}
+	return chunks
+}
@@ -0,0 +1,66 @@
+package v1beta1
+
+import (
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo/v2" //revive:disable:dot-imports
+	. "github.com/onsi/gomega"    //revive:disable:dot-imports
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("VerifyBaremetalSetAdoption", func() {
+	var instance *OpenStackBaremetalSet
+	var allBmhs *metal3v1.BareMetalHostList
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: "openstack"},
+		}
+		instance.Spec.BmhNamespace = "openshift-machine-api"
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {CtlPlaneIP: "10.0.0.1/24"},
+			"compute-1": {CtlPlaneIP: "10.0.0.2/24", AdoptBmh: "bmh-0"},
+		}
+
+		provisioned := bmhWithLabels("bmh-0", nil)
+		provisioned.Status.Provisioning.State = metal3v1.StateProvisioned
+		allBmhs = &metal3v1.BareMetalHostList{Items: []metal3v1.BareMetalHost{provisioned}}
+	})
+
+	It("returns the BMH to adopt per host", func() {
+		adopted, err := VerifyBaremetalSetAdoption(instance, allBmhs, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(adopted).To(HaveKey("compute-1"))
+	})
+
+	It("rejects a BMH already allocated to another host of the set", func() {
+		allBmhs.Items[0].Spec.ConsumerRef = &corev1.ObjectReference{Name: "compute", Namespace: "openstack"}
+		instance.Status.BaremetalHosts = map[string]HostStatus{
+			"compute-0": {IPStatus: IPStatus{Hostname: "compute-0", BmhRef: "bmh-0"}},
+		}
+		_, err := VerifyBaremetalSetAdoption(instance, allBmhs, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("cannot be adopted by compute-1: it is already used by host compute-0"))
+	})
+
+	It("rejects a BMH another host of the set is pinned to", func() {
+		instance.Spec.BaremetalHosts["compute-0"] = InstanceSpec{CtlPlaneIP: "10.0.0.1/24", BmhName: "bmh-0"}
+		_, err := VerifyBaremetalSetAdoption(instance, allBmhs, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("it is already used by host compute-0"))
+	})
+
+	It("rejects a BMH a host of another set pins or adopts", func() {
+		otherSet := OpenStackBaremetalSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "openstack"},
+		}
+		otherSet.Spec.BmhNamespace = instance.Spec.BmhNamespace
+		otherSet.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"other-0": {CtlPlaneIP: "10.0.1.1/24", AdoptBmh: "bmh-0"},
+		}
+		_, err := VerifyBaremetalSetAdoption(instance, allBmhs, []OpenStackBaremetalSet{*instance, otherSet})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(
+			"it is already pinned or adopted by host other-0 of OpenStackBaremetalSet openstack/other"))
+	})
+})
@@ -0,0 +1,302 @@
+package v1beta1
+
+import (
+	"github.com/go-logr/logr"
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo/v2" //revive:disable:dot-imports
+	. "github.com/onsi/gomega"    //revive:disable:dot-imports
+)
+
+var _ = Describe("verifyBaremetalSetHardwareMatch", func() {
+	var instance *OpenStackBaremetalSet
+	var bmh metal3v1.BareMetalHost
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{}
+		bmh = bmhWithHardware("compute", 128, 32, 0, nil)
+		bmh.Status.HardwareDetails.Storage = []metal3v1.Storage{
+			{SizeBytes: 480 * 1073741824, Type: metal3v1.SSD},
+			{SizeBytes: 1920 * 1073741824, Type: metal3v1.NVME},
+			{SizeBytes: 1920 * 1073741824, Type: metal3v1.NVME},
+			{SizeBytes: 8000 * 1073741824, Type: metal3v1.HDD, Rotational: true},
+		}
+		bmh.Status.HardwareDetails.NIC = []metal3v1.NIC{
+			{Name: "eno1", SpeedGbps: 1},
+			{Name: "ens1f0", SpeedGbps: 25},
+			{Name: "ens1f1", SpeedGbps: 25},
+		}
+		bmh.Status.HardwareDetails.SystemVendor = metal3v1.HardwareSystemVendor{
+			Manufacturer: "Dell Inc.",
+			ProductName:  "PowerEdge R650",
+		}
+		bmh.Status.HardwareDetails.Firmware.BIOS.Version = "1.10.2"
+	})
+
+	matches := func() bool {
+		return verifyBaremetalSetHardwareMatch(logr.Discard(), instance.Spec.HardwareReqs, &bmh)
+	}
+
+	It("counts the disks of at least the requested size", func() {
+		instance.Spec.HardwareReqs.DiskReqs.GbReq.Gb = 1000
+		instance.Spec.HardwareReqs.DiskReqs.CountReq.Count = 3
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.DiskReqs.CountReq.ExactMatch = true
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.DiskReqs.CountReq.Count = 4
+		Expect(matches()).To(BeFalse())
+	})
+
+	It("applies the SSD request to the counted disks", func() {
+		instance.Spec.HardwareReqs.DiskReqs.GbReq.Gb = 1000
+		instance.Spec.HardwareReqs.DiskReqs.SSDReq.SSD = true
+		instance.Spec.HardwareReqs.DiskReqs.CountReq.Count = 2
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.DiskReqs.CountReq.Count = 3
+		Expect(matches()).To(BeFalse())
+	})
+
+	It("only prefers NVMe disks", func() {
+		instance.Spec.HardwareReqs.DiskReqs.SSDReq.SSD = true
+		instance.Spec.HardwareReqs.DiskReqs.CountReq.Count = 3
+		instance.Spec.HardwareReqs.DiskReqs.NVMeReq.NVMe = true
+		Expect(matches()).To(BeTrue())
+		Expect(hasPreferredDisks(instance.Spec.HardwareReqs.DiskReqs, &bmh)).To(BeFalse())
+
+		instance.Spec.HardwareReqs.DiskReqs.CountReq.Count = 2
+		Expect(hasPreferredDisks(instance.Spec.HardwareReqs.DiskReqs, &bmh)).To(BeTrue())
+	})
+
+	It("requires a single disk to satisfy both size and SSD requests", func() {
+		instance.Spec.HardwareReqs.DiskReqs.GbReq.Gb = 4000
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.DiskReqs.SSDReq.SSD = true
+		Expect(matches()).To(BeFalse())
+	})
+
+	It("matches the NIC count and speed", func() {
+		instance.Spec.HardwareReqs.NICReqs.SpeedReq.Gbps = 25
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.NICReqs.CountReq.Count = 2
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.NICReqs.CountReq.Count = 3
+		Expect(matches()).To(BeFalse())
+
+		instance.Spec.HardwareReqs.NICReqs.SpeedReq.Gbps = 1
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.NICReqs.SpeedReq.ExactMatch = true
+		Expect(matches()).To(BeFalse())
+
+		instance.Spec.HardwareReqs.NICReqs.SpeedReq.Gbps = 100
+		instance.Spec.HardwareReqs.NICReqs.SpeedReq.ExactMatch = false
+		instance.Spec.HardwareReqs.NICReqs.CountReq.Count = 0
+		Expect(matches()).To(BeFalse())
+	})
+
+	It("matches the aarch64 CPU arch", func() {
+		bmh.Status.HardwareDetails.CPU.Arch = "aarch64"
+		instance.Spec.HardwareReqs.CPUReqs.Arch = "aarch64"
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.CPUReqs.Arch = "x86_64"
+		Expect(matches()).To(BeFalse())
+	})
+
+	It("matches the system vendor exactly", func() {
+		instance.Spec.HardwareReqs.SystemReqs.Manufacturer = "Dell Inc."
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.SystemReqs.ProductName = "PowerEdge R650"
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.SystemReqs.ProductName = "PowerEdge R750"
+		Expect(matches()).To(BeFalse())
+	})
+
+	It("requires a minimum BIOS version", func() {
+		instance.Spec.HardwareReqs.FirmwareReqs.MinBIOSVersion = "1.9.0"
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.FirmwareReqs.MinBIOSVersion = "1.10.2"
+		Expect(matches()).To(BeTrue())
+
+		instance.Spec.HardwareReqs.FirmwareReqs.MinBIOSVersion = "1.11"
+		Expect(matches()).To(BeFalse())
+
+		instance.Spec.HardwareReqs.FirmwareReqs.MinBIOSVersion = "1.9.0"
+		bmh.Status.HardwareDetails.Firmware.BIOS.Version = ""
+		Expect(matches()).To(BeFalse())
+	})
+})
+
+var _ = Describe("HardwareReqs Merge", func() {
+	It("replaces only the requests set in the overrides", func() {
+		reqs := HardwareReqs{}
+		reqs.CPUReqs.Arch = "x86_64"
+		reqs.MemReqs.GbReq.Gb = 64
+		reqs.DiskReqs.GbReq.Gb = 100
+		reqs.DiskReqs.SSDReq.SSD = true
+
+		overrides := &HardwareReqs{}
+		overrides.DiskReqs.GbReq = DiskGbReq{Gb: 4000, ExactMatch: true}
+		overrides.DiskReqs.CountReq.Count = 4
+
+		merged := reqs.Merge(overrides)
+		Expect(merged.CPUReqs.Arch).To(Equal("x86_64"))
+		Expect(merged.MemReqs.GbReq.Gb).To(Equal(64))
+		Expect(merged.DiskReqs.GbReq).To(Equal(DiskGbReq{Gb: 4000, ExactMatch: true}))
+		Expect(merged.DiskReqs.SSDReq.SSD).To(BeTrue())
+		Expect(merged.DiskReqs.CountReq.Count).To(Equal(4))
+
+		Expect(reqs.DiskReqs.GbReq.Gb).To(Equal(100))
+		Expect(reqs.Merge(nil)).To(Equal(reqs))
+	})
+})
+
+var _ = Describe("VerifyBaremetalSetScaleUp host hardwareReqs", func() {
+	var instance *OpenStackBaremetalSet
+	var allBmhs *metal3v1.BareMetalHostList
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{}
+		instance.Spec.BmhNamespace = "openstack"
+		instance.Spec.HardwareReqs.MemReqs.GbReq.Gb = 128
+		instance.Spec.HardwareReqs.DiskReqs.GbReq.Gb = 400
+
+		storage := &HardwareReqs{}
+		storage.DiskReqs.GbReq.Gb = 4000
+		storage.DiskReqs.CountReq.Count = 2
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {},
+			"storage-0": {HardwareReqs: storage},
+		}
+
+		storageBmh := bmhWithHardware("bmh-storage", 256, 32, 4000, nil)
+		storageBmh.Status.HardwareDetails.Storage = append(storageBmh.Status.HardwareDetails.Storage,
+			metal3v1.Storage{SizeBytes: 4000 * 1073741824})
+		allBmhs = &metal3v1.BareMetalHostList{
+			Items: []metal3v1.BareMetalHost{
+				storageBmh,
+				bmhWithHardware("bmh-regular", 128, 32, 480, nil),
+				bmhWithHardware("bmh-small", 64, 32, 480, nil),
+			},
+		}
+	})
+
+	It("matches each host against its own requests merged with the set's", func() {
+		selected, err := VerifyBaremetalSetScaleUp(logr.Discard(), BinPackingBmhScorer{}, instance, allBmhs, &metal3v1.BareMetalHostList{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected["compute-0"].Name).To(Equal("bmh-regular"))
+		Expect(selected["storage-0"].Name).To(Equal("bmh-storage"))
+	})
+
+	It("still applies the set's requests the host doesn't override", func() {
+		allBmhs.Items[0].Status.HardwareDetails.RAMMebibytes = 64 * 1024
+		allBmhs.Items = append(allBmhs.Items, bmhWithHardware("bmh-regular-2", 128, 32, 480, nil))
+
+		_, err := VerifyBaremetalSetScaleUp(logr.Discard(), BinPackingBmhScorer{}, instance, allBmhs, &metal3v1.BareMetalHostList{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("(unmatched hosts: storage-0)"))
+	})
+
+	It("reports the BMHs satisfying the requests of any host as candidates", func() {
+		capacity := GetBaremetalSetCapacity(instance, allBmhs)
+		Expect(capacity.Candidates).To(Equal([]string{"bmh-regular", "bmh-storage"}))
+		Expect(capacity.Rejected).To(HaveLen(1))
+		Expect(capacity.Rejected[0].Name).To(Equal("bmh-small"))
+	})
+})
+
+var _ = Describe("BaremetalHostRootDeviceHints", func() {
+	var instance *OpenStackBaremetalSet
+	var bmh metal3v1.BareMetalHost
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{}
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {},
+		}
+		instance.Spec.HardwareReqs.DiskReqs.NVMeReq.NVMe = true
+
+		bmh = bmhWithHardware("compute", 128, 32, 0, nil)
+		bmh.Status.HardwareDetails.Storage = []metal3v1.Storage{
+			{Name: "/dev/sda", SizeBytes: 480 * 1073741824, Type: metal3v1.SSD, WWN: "0x5000c500a1b2c3d4", SerialNumber: "S1"},
+			{Name: "/dev/nvme0n1", SizeBytes: 1920 * 1073741824, Type: metal3v1.NVME, WWN: "eui.0025388b91b2c3d4", SerialNumber: "S2"},
+			{Name: "/dev/nvme1n1", SizeBytes: 1920 * 1073741824, Type: metal3v1.NVME, SerialNumber: "S3"},
+		}
+	})
+
+	It("has no hints unless asked for", func() {
+		Expect(BaremetalHostRootDeviceHints(instance, "compute-0", &bmh)).To(BeNil())
+	})
+
+	It("derives the hints from the disk satisfying the diskReqs", func() {
+		instance.Spec.RootDeviceHintsFrom = RootDeviceHintsFromWWN
+		Expect(BaremetalHostRootDeviceHints(instance, "compute-0", &bmh)).To(
+			Equal(&metal3v1.RootDeviceHints{WWN: "eui.0025388b91b2c3d4"}))
+
+		instance.Spec.RootDeviceHintsFrom = RootDeviceHintsFromSerialNumber
+		Expect(BaremetalHostRootDeviceHints(instance, "compute-0", &bmh)).To(
+			Equal(&metal3v1.RootDeviceHints{SerialNumber: "S2"}))
+	})
+
+	It("falls back to the first disk satisfying the diskReqs without a preferred one", func() {
+		instance.Spec.RootDeviceHintsFrom = RootDeviceHintsFromSerialNumber
+		bmh.Status.HardwareDetails.Storage[1].Type = metal3v1.SSD
+		bmh.Status.HardwareDetails.Storage[2].Type = metal3v1.SSD
+		Expect(BaremetalHostRootDeviceHints(instance, "compute-0", &bmh)).To(
+			Equal(&metal3v1.RootDeviceHints{SerialNumber: "S1"}))
+	})
+
+	It("falls back to the serial number of disks without WWN", func() {
+		instance.Spec.RootDeviceHintsFrom = RootDeviceHintsFromWWN
+		bmh.Status.HardwareDetails.Storage[1].WWN = ""
+		Expect(BaremetalHostRootDeviceHints(instance, "compute-0", &bmh)).To(
+			Equal(&metal3v1.RootDeviceHints{SerialNumber: "S2"}))
+	})
+
+	It("uses the diskReqs of the host", func() {
+		instance.Spec.RootDeviceHintsFrom = RootDeviceHintsFromSerialNumber
+		overrides := &HardwareReqs{}
+		overrides.DiskReqs.GbReq.Gb = 400
+		overrides.DiskReqs.GbReq.ExactMatch = false
+		instance.Spec.HardwareReqs.DiskReqs.NVMeReq.NVMe = false
+		instance.Spec.BaremetalHosts["compute-0"] = InstanceSpec{HardwareReqs: overrides}
+		Expect(BaremetalHostRootDeviceHints(instance, "compute-0", &bmh)).To(
+			Equal(&metal3v1.RootDeviceHints{SerialNumber: "S1"}))
+	})
+
+	It("prefers the hints given for the host, then for the set", func() {
+		instance.Spec.RootDeviceHintsFrom = RootDeviceHintsFromWWN
+		instance.Spec.RootDeviceHints = &metal3v1.RootDeviceHints{DeviceName: "/dev/sda"}
+		Expect(BaremetalHostRootDeviceHints(instance, "compute-0", &bmh)).To(
+			Equal(&metal3v1.RootDeviceHints{DeviceName: "/dev/sda"}))
+
+		instance.Spec.BaremetalHosts["compute-0"] = InstanceSpec{
+			RootDeviceHints: &metal3v1.RootDeviceHints{HCTL: "0:0:0:1"},
+		}
+		Expect(BaremetalHostRootDeviceHints(instance, "compute-0", &bmh)).To(
+			Equal(&metal3v1.RootDeviceHints{HCTL: "0:0:0:1"}))
+	})
+})
+
+var _ = Describe("compareVersions", func() {
+	It("compares digit runs numerically", func() {
+		Expect(compareVersions("2.10.1", "2.9")).To(Equal(1))
+		Expect(compareVersions("2.9", "2.10.1")).To(Equal(-1))
+		Expect(compareVersions("2.9.0", "2.9.0")).To(Equal(0))
+		Expect(compareVersions("2.9", "2.9.0")).To(Equal(-1))
+	})
+
+	It("compares the rest lexically, ignoring separators", func() {
+		Expect(compareVersions("U46 v2.70", "U46-v2.68")).To(Equal(1))
+		Expect(compareVersions("P89", "U46")).To(Equal(-1))
+		Expect(compareVersions("1.2b", "1.2a")).To(Equal(1))
+	})
+})
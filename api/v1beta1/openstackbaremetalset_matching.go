@@ -0,0 +1,89 @@
+package v1beta1
+
+import "math"
+
+// maximumBipartiteMatching - Hopcroft-Karp maximum matching of left vertices (computes) to right vertices
+// (BMHs), in O(E*sqrt(V)). adjacency lists the right vertices of each left vertex, in order of preference.
+// The search starts from the greedy matching of every left vertex, in order, to its first free right vertex,
+// so preferences only give way where needed to match more left vertices. Returns the right vertex matched to
+// each left vertex, -1 for those left unmatched
+func maximumBipartiteMatching(adjacency [][]int, rightCount int) []int {
+	const unmatched = -1
+	const infinity = math.MaxInt
+
+	matchLeft := make([]int, len(adjacency))
+	for u := range matchLeft {
+		matchLeft[u] = unmatched
+	}
+	matchRight := make([]int, rightCount)
+	for v := range matchRight {
+		matchRight[v] = unmatched
+	}
+
+	for u, rights := range adjacency {
+		for _, v := range rights {
+			if matchRight[v] == unmatched {
+				matchLeft[u] = v
+				matchRight[v] = u
+				break
+			}
+		}
+	}
+
+	// dist - BFS layer of the left vertices in the alternating paths starting at the unmatched ones
+	dist := make([]int, len(adjacency))
+
+	// bfs - Layer the left vertices, returning whether any augmenting path exists
+	bfs := func() bool {
+		queue := make([]int, 0, len(adjacency))
+		for u := range adjacency {
+			if matchLeft[u] == unmatched {
+				dist[u] = 0
+				queue = append(queue, u)
+			} else {
+				dist[u] = infinity
+			}
+		}
+
+		found := false
+		for head := 0; head < len(queue); head++ {
+			u := queue[head]
+			for _, v := range adjacency[u] {
+				w := matchRight[v]
+				if w == unmatched {
+					found = true
+				} else if dist[w] == infinity {
+					dist[w] = dist[u] + 1
+					queue = append(queue, w)
+				}
+			}
+		}
+		return found
+	}
+
+	// dfs - Augment along a shortest alternating path from left vertex u, if there is one
+	var dfs func(u int) bool
+	dfs = func(u int) bool {
+		for _, v := range adjacency[u] {
+			w := matchRight[v]
+			if w == unmatched || (dist[w] == dist[u]+1 && dfs(w)) {
+				matchLeft[u] = v
+				matchRight[v] = u
+				return true
+			}
+		}
+		// Dead end, don't explore it again in this phase
+		dist[u] = infinity
+		return false
+	}
+
+	for bfs() {
+		for u := range adjacency {
+			if matchLeft[u] == unmatched {
+				dfs(u)
+			}
+		}
+	}
+
+	return matchLeft
+}
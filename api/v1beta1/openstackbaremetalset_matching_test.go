@@ -0,0 +1,250 @@
+package v1beta1
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo/v2" //revive:disable:dot-imports
+	. "github.com/onsi/gomega"    //revive:disable:dot-imports
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+)
+
+// bmhWithLabels - An available BMH with the given labels
+func bmhWithLabels(name string, labels map[string]string) metal3v1.BareMetalHost {
+	return metal3v1.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Status: metal3v1.BareMetalHostStatus{
+			Provisioning: metal3v1.ProvisionStatus{
+				State: metal3v1.StateAvailable,
+			},
+		},
+	}
+}
+
+// rackedComputesAndBmhs - computeCount computes each selecting one of racks racks, some of them more than
+// one rack, and bmhCount BMHs spread over the racks
+func rackedComputesAndBmhs(computeCount int, bmhCount int, racks int) (map[string]InstanceSpec, []metal3v1.BareMetalHost) {
+	computes := map[string]InstanceSpec{}
+	for i := range computeCount {
+		compute := InstanceSpec{
+			BmhLabelSelector: map[string]string{"app": "openstack"},
+		}
+		if i%3 == 0 {
+			compute.BmhMatchExpressions = []metav1.LabelSelectorRequirement{{
+				Key:      "rack",
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   []string{fmt.Sprintf("r%d", i%racks), fmt.Sprintf("r%d", (i+1)%racks)},
+			}}
+		} else {
+			compute.BmhLabelSelector["rack"] = fmt.Sprintf("r%d", i%racks)
+		}
+		computes[fmt.Sprintf("compute-%d", i)] = compute
+	}
+
+	bmhs := []metal3v1.BareMetalHost{}
+	for i := range bmhCount {
+		bmhs = append(bmhs, bmhWithLabels(fmt.Sprintf("bmh-%04d", i), map[string]string{
+			"app":  "openstack",
+			"rack": fmt.Sprintf("r%d", i%racks),
+		}))
+	}
+
+	return computes, bmhs
+}
+
+var _ = Describe("maximumBipartiteMatching", func() {
+	It("matches everything when the greedy matching can't", func() {
+		// BMH0 (A, B, C), BMH1 (A, C), BMH2 (A, B) for COMP0 (A, B), COMP1 (A, C), COMP2 (A, B).
+		// Greedily COMP0 gets BMH2 and COMP1 BMH0, leaving nothing for COMP2
+		adjacency := [][]int{
+			{2, 0},
+			{0, 1},
+			{0, 2},
+		}
+		Expect(maximumBipartiteMatching(adjacency, 3)).To(Equal([]int{2, 1, 0}))
+	})
+
+	It("keeps the preferred vertices when nothing else needs them", func() {
+		adjacency := [][]int{
+			{2, 1, 0},
+			{1, 0},
+		}
+		Expect(maximumBipartiteMatching(adjacency, 3)).To(Equal([]int{2, 1}))
+	})
+
+	It("leaves the vertices it can't match unmatched", func() {
+		adjacency := [][]int{
+			{0},
+			{0},
+			{},
+			{0, 1},
+		}
+		matching := maximumBipartiteMatching(adjacency, 2)
+		Expect(matching).To(Equal([]int{0, -1, -1, 1}))
+	})
+})
+
+var _ = Describe("findValidBaremetalSetInstanceLabelAssignments", func() {
+	It("assigns every compute a BMH matching its labels", func() {
+		computes := map[string]InstanceSpec{
+			"compute-0": {BmhLabelSelector: map[string]string{"a": "1", "b": "1"}},
+			"compute-1": {BmhLabelSelector: map[string]string{"a": "1", "c": "1"}},
+			"compute-2": {BmhLabelSelector: map[string]string{"a": "1", "b": "1"}},
+		}
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithLabels("bmh-0", map[string]string{"a": "1", "b": "1", "c": "1"}),
+			bmhWithLabels("bmh-1", map[string]string{"a": "1", "c": "1"}),
+			bmhWithLabels("bmh-2", map[string]string{"a": "1", "b": "1"}),
+		}
+
+		assignment, unmatched := findValidBaremetalSetInstanceLabelAssignments(computes, bmhs)
+		Expect(unmatched).To(BeEmpty())
+		Expect(assignment).To(HaveLen(3))
+		Expect(assignment["compute-1"].Name).To(Equal("bmh-1"))
+		Expect([]string{assignment["compute-0"].Name, assignment["compute-2"].Name}).To(ConsistOf("bmh-0", "bmh-2"))
+	})
+
+	It("only assigns a pinned compute its own BMH", func() {
+		computes := map[string]InstanceSpec{
+			"compute-0": {BmhName: "bmh-1"},
+			"compute-1": {},
+		}
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithLabels("bmh-0", nil),
+			bmhWithLabels("bmh-1", nil),
+		}
+
+		assignment, unmatched := findValidBaremetalSetInstanceLabelAssignments(computes, bmhs)
+		Expect(unmatched).To(BeEmpty())
+		Expect(assignment["compute-0"].Name).To(Equal("bmh-1"))
+		Expect(assignment["compute-1"].Name).To(Equal("bmh-0"))
+	})
+
+	It("reports the computes left unmatched", func() {
+		computes := map[string]InstanceSpec{
+			"compute-0": {BmhLabelSelector: map[string]string{"rack": "r1"}},
+			"compute-1": {BmhLabelSelector: map[string]string{"rack": "r1"}},
+			"compute-2": {BmhLabelSelector: map[string]string{"rack": "r1"}},
+			"compute-3": {BmhLabelSelector: map[string]string{"rack": "r2"}},
+		}
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithLabels("bmh-0", map[string]string{"rack": "r1"}),
+			bmhWithLabels("bmh-1", map[string]string{"rack": "r1"}),
+			bmhWithLabels("bmh-2", map[string]string{"rack": "r2"}),
+			bmhWithLabels("bmh-3", map[string]string{"rack": "r3"}),
+		}
+
+		assignment, unmatched := findValidBaremetalSetInstanceLabelAssignments(computes, bmhs)
+		Expect(assignment).To(BeNil())
+		Expect(unmatched).To(Equal([]string{"compute-2"}))
+	})
+
+	It("matches 1000 computes to 1000 BMHs with overlapping selectors", func() {
+		computes, bmhs := rackedComputesAndBmhs(1000, 1000, 10)
+
+		assignment, unmatched := findValidBaremetalSetInstanceLabelAssignments(computes, bmhs)
+		Expect(unmatched).To(BeEmpty())
+		Expect(assignment).To(HaveLen(1000))
+
+		used := map[string]bool{}
+		for compName, bmh := range assignment {
+			selector, err := computes[compName].BmhSelector()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selector.Matches(k8s_labels.Set(bmh.GetLabels()))).To(BeTrue())
+			Expect(used[bmh.Name]).To(BeFalse())
+			used[bmh.Name] = true
+		}
+	})
+})
+
+var _ = Describe("VerifyBaremetalSetScaleUp label matching", func() {
+	It("names the hosts that could not be matched", func() {
+		instance := &OpenStackBaremetalSet{}
+		instance.Spec.BmhNamespace = "openstack"
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {BmhLabelSelector: map[string]string{"rack": "r1"}},
+			"compute-1": {BmhLabelSelector: map[string]string{"rack": "r1"}},
+		}
+		allBmhs := &metal3v1.BareMetalHostList{
+			Items: []metal3v1.BareMetalHost{
+				bmhWithLabels("bmh-0", map[string]string{"rack": "r1"}),
+				bmhWithLabels("bmh-1", map[string]string{"rack": "r2"}),
+			},
+		}
+
+		_, err := VerifyBaremetalSetScaleUp(logr.Discard(), BinPackingBmhScorer{}, instance, allBmhs, &metal3v1.BareMetalHostList{})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("(unmatched hosts: compute-1)"))
+	})
+
+	It("skips the BMHs that already failed to provision for the set", func() {
+		instance := &OpenStackBaremetalSet{}
+		instance.Name = "compute"
+		instance.Namespace = "openstack"
+		instance.Spec.BmhNamespace = "openstack"
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{"compute-0": {}}
+		other := &OpenStackBaremetalSet{}
+		other.Name = "other"
+		other.Namespace = "openstack"
+		allBmhs := &metal3v1.BareMetalHostList{
+			Items: []metal3v1.BareMetalHost{
+				bmhWithLabels("bmh-0", nil),
+				bmhWithLabels("bmh-1", nil),
+			},
+		}
+		MarkBaremetalHostFailed(other, &allBmhs.Items[0])
+		MarkBaremetalHostFailed(instance, &allBmhs.Items[0])
+		MarkBaremetalHostFailed(instance, &allBmhs.Items[0])
+		Expect(allBmhs.Items[0].Annotations[FailedBmhAnnotation]).To(Equal("openstack/other,openstack/compute"))
+
+		selected, err := VerifyBaremetalSetScaleUp(logr.Discard(), BinPackingBmhScorer{}, instance, allBmhs, &metal3v1.BareMetalHostList{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected["compute-0"].Name).To(Equal("bmh-1"))
+	})
+})
+
+func BenchmarkFindValidBaremetalSetInstanceLabelAssignments(b *testing.B) {
+	for _, size := range []struct {
+		computes int
+		bmhs     int
+		racks    int
+	}{
+		{100, 100, 10},
+		{1000, 1000, 10},
+		{1000, 1000, 100},
+		// One BMH short, so that no full matching exists
+		{1000, 999, 10},
+	} {
+		b.Run(fmt.Sprintf("%dx%d/%d-racks", size.computes, size.bmhs, size.racks), func(b *testing.B) {
+			computes, bmhs := rackedComputesAndBmhs(size.computes, size.bmhs, size.racks)
+			b.ResetTimer()
+			for range b.N {
+				findValidBaremetalSetInstanceLabelAssignments(computes, bmhs)
+			}
+		})
+	}
+}
+
+func BenchmarkMaximumBipartiteMatching(b *testing.B) {
+	// Compute i can use BMH i and any after it, preferring the last ones. Greedily the first half of
+	// the computes take the second half of the BMHs, so the second half of the computes all need
+	// augmenting paths
+	const size = 1000
+	adjacency := make([][]int, size)
+	for u := range adjacency {
+		for v := size - 1; v >= u; v-- {
+			adjacency[u] = append(adjacency[u], v)
+		}
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		maximumBipartiteMatching(adjacency, size)
+	}
+}
@@ -0,0 +1,300 @@
+package v1beta1
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CtlplaneNetworkName - Name of the ctlplane network in the host status ipAddresses
+const CtlplaneNetworkName = "ctlplane"
+
+// Merge - The network with the fields set in overrides replacing its own
+func (n NetworkSpec) Merge(overrides NetworkSpec) NetworkSpec {
+	if overrides.Interface != "" {
+		n.Interface = overrides.Interface
+	}
+	if overrides.Bond != nil {
+		n.Bond = overrides.Bond
+	}
+	if overrides.Vlan != nil {
+		n.Vlan = overrides.Vlan
+	}
+	if overrides.IP != "" {
+		n.IP = overrides.IP
+	}
+	if overrides.Gateway != "" {
+		n.Gateway = overrides.Gateway
+	}
+	if overrides.Routes != nil {
+		n.Routes = overrides.Routes
+	}
+	if overrides.MTU != 0 {
+		n.MTU = overrides.MTU
+	}
+	return n
+}
+
+// BaremetalHostNetworks - The networks of a host besides ctlplane: those of the set, merged with what the host
+// gives for them, followed by those of the host only
+func BaremetalHostNetworks(instance *OpenStackBaremetalSet, hostName string) []NetworkSpec {
+	hostNetworks := instance.Spec.BaremetalHosts[hostName].Networks
+	networks := []NetworkSpec{}
+
+	for _, network := range instance.Spec.Networks {
+		i := slices.IndexFunc(hostNetworks, func(n NetworkSpec) bool { return n.Name == network.Name })
+		if i >= 0 {
+			network = network.Merge(hostNetworks[i])
+		}
+		networks = append(networks, *network.DeepCopy())
+	}
+
+	for _, network := range hostNetworks {
+		if !slices.ContainsFunc(instance.Spec.Networks, func(n NetworkSpec) bool { return n.Name == network.Name }) {
+			networks = append(networks, *network.DeepCopy())
+		}
+	}
+
+	return networks
+}
+
+// BaremetalHostCtlplaneAddresses - The ctlplane addresses of a host, from ctlPlaneIPs or else ctlPlaneIP. The
+// ctlplaneGateway of the host, or else of the set, goes to the address of its IP version unless it has a gateway
+// of its own
+func BaremetalHostCtlplaneAddresses(instance *OpenStackBaremetalSet, hostName string) []CtlplaneAddress {
+	compute := instance.Spec.BaremetalHosts[hostName]
+	addresses := slices.Clone(compute.CtlPlaneIPs)
+	if len(addresses) == 0 {
+		addresses = []CtlplaneAddress{{IP: compute.CtlPlaneIP}}
+	}
+
+	gateway := compute.CtlplaneGateway
+	if gateway == "" {
+		gateway = instance.Spec.CtlplaneGateway
+	}
+	for i := range addresses {
+		if addresses[i].Gateway == "" && IPVersion(cidrIP(addresses[i].IP)) == IPVersion(net.ParseIP(gateway)) {
+			addresses[i].Gateway = gateway
+		}
+	}
+
+	return addresses
+}
+
+// BaremetalHostCtlplaneRoutes - The static ctlplane routes of a host, its own or else those of the set
+func BaremetalHostCtlplaneRoutes(instance *OpenStackBaremetalSet, hostName string) []Route {
+	if routes := instance.Spec.BaremetalHosts[hostName].CtlplaneRoutes; routes != nil {
+		return routes
+	}
+	return instance.Spec.CtlplaneRoutes
+}
+
+// BaremetalHostIPAddresses - The IPs of a host by network name, as recorded in its status. The first ctlplane
+// address is recorded as ctlplane, the other one by its IP version, e.g. ctlplane-ipv6
+func BaremetalHostIPAddresses(instance *OpenStackBaremetalSet, hostName string) map[string]string {
+	ipAddresses := map[string]string{}
+	for i, address := range BaremetalHostCtlplaneAddresses(instance, hostName) {
+		if i == 0 {
+			ipAddresses[CtlplaneNetworkName] = address.IP
+		} else {
+			ipAddresses[fmt.Sprintf("%s-%s", CtlplaneNetworkName, IPVersion(cidrIP(address.IP)))] = address.IP
+		}
+	}
+	for _, network := range BaremetalHostNetworks(instance, hostName) {
+		// Hosts bringing their own networkData need not give an ip on the networks of the set
+		if network.IP != "" {
+			ipAddresses[network.Name] = network.IP
+		}
+	}
+	return ipAddresses
+}
+
+// IPVersion - ipv4 or ipv6, the IP version of an IP, "" if it is nil
+func IPVersion(ip net.IP) string {
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return "ipv4"
+	default:
+		return "ipv6"
+	}
+}
+
+// cidrIP - The IP of an IP in CIDR notation, nil if it is invalid
+func cidrIP(cidr string) net.IP {
+	ip, _, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil
+	}
+	return ip
+}
+
+// BaremetalHostCtlplaneBond - The ctlplane bonding configuration of a host, its own or else the one of the set
+func BaremetalHostCtlplaneBond(instance *OpenStackBaremetalSet, hostName string) *BondConfig {
+	if bond := instance.Spec.BaremetalHosts[hostName].CtlplaneBond; bond != nil {
+		return bond
+	}
+	return instance.Spec.CtlplaneBond
+}
+
+// VerifyBaremetalSetNetworks - Check that the ctlplane addresses, routes and bonds of the hosts are valid, that
+// every host has an IP on each of its networks, and that the addresses, routes and bonds of the networks are valid.
+// The networks of hosts bringing their own networkData are not rendered, so they are not checked
+func VerifyBaremetalSetNetworks(instance *OpenStackBaremetalSet) error {
+	if instance.Spec.CtlplaneBond != nil {
+		if err := verifyBond(*instance.Spec.CtlplaneBond); err != nil {
+			return fmt.Errorf("ctlplaneBond: %w", err)
+		}
+	}
+	for _, network := range instance.Spec.Networks {
+		if network.IP != "" {
+			return fmt.Errorf("networks: %s: ip must be given in the networks of baremetalHosts", network.Name)
+		}
+	}
+
+	hostNames := []string{}
+	for hostName := range instance.Spec.BaremetalHosts {
+		hostNames = append(hostNames, hostName)
+	}
+	sort.Strings(hostNames)
+
+	for _, hostName := range hostNames {
+		compute := instance.Spec.BaremetalHosts[hostName]
+		if err := verifyCtlplaneAddresses(compute); err != nil {
+			return fmt.Errorf("host %s: %w", hostName, err)
+		}
+		if bond := compute.CtlplaneBond; bond != nil {
+			if err := verifyBond(*bond); err != nil {
+				return fmt.Errorf("host %s: ctlplaneBond: %w", hostName, err)
+			}
+		}
+		// The ctlplane routes are not rendered for hosts bringing their own networkData, nor without
+		// ctlplane address, so they don't need a ctlplane address of their IP version there
+		var addresses []CtlplaneAddress
+		if compute.NetworkData == nil && (compute.CtlPlaneIP != "" || len(compute.CtlPlaneIPs) > 0) {
+			addresses = BaremetalHostCtlplaneAddresses(instance, hostName)
+		}
+		if err := verifyCtlplaneRoutes(BaremetalHostCtlplaneRoutes(instance, hostName), addresses); err != nil {
+			return fmt.Errorf("host %s: %w", hostName, err)
+		}
+		// Nor are the other networks, which hosts bringing their own networkData configure themselves
+		if compute.NetworkData != nil {
+			continue
+		}
+		for _, network := range BaremetalHostNetworks(instance, hostName) {
+			if err := verifyNetwork(network); err != nil {
+				return fmt.Errorf("host %s: network %s: %w", hostName, network.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyNetwork - Check the addresses and routes of a network of a host
+func verifyNetwork(network NetworkSpec) error {
+	if network.Name == CtlplaneNetworkName {
+		return fmt.Errorf("%s is configured through ctlPlaneIP", CtlplaneNetworkName)
+	}
+	if network.IP == "" {
+		return fmt.Errorf("no ip given")
+	}
+	if _, _, err := net.ParseCIDR(network.IP); err != nil {
+		return fmt.Errorf("ip %s is not in CIDR notation", network.IP)
+	}
+	if network.Gateway != "" && net.ParseIP(network.Gateway) == nil {
+		return fmt.Errorf("invalid gateway %s", network.Gateway)
+	}
+	for _, route := range network.Routes {
+		if err := verifyRoute(route); err != nil {
+			return err
+		}
+	}
+	if network.Bond != nil {
+		if err := verifyBond(*network.Bond); err != nil {
+			return fmt.Errorf("bond: %w", err)
+		}
+	}
+	return nil
+}
+
+// verifyBond - Check that a bond uses a kernel bonding mode, and only kernel bonding options besides the mode
+func verifyBond(bond BondConfig) error {
+	if bond.BondMode != "" && !slices.Contains(BondModes, bond.BondMode) {
+		return fmt.Errorf("invalid bondMode %q, must be one of %s", bond.BondMode, strings.Join(BondModes, ", "))
+	}
+	for _, option := range slices.Sorted(maps.Keys(bond.BondOptions)) {
+		if !slices.Contains(BondOptionNames, strings.TrimPrefix(option, BondOptionPrefix)) {
+			return fmt.Errorf("invalid bondOptions key %q, must be one of %s (optionally prefixed with %s)",
+				option, strings.Join(BondOptionNames, ", "), BondOptionPrefix)
+		}
+	}
+	return nil
+}
+
+// verifyRoute - Check that a static route has a destination network and a next hop of the same IP version
+func verifyRoute(route Route) error {
+	version := IPVersion(cidrIP(route.Destination))
+	if version == "" {
+		return fmt.Errorf("route destination %s is not in CIDR notation", route.Destination)
+	}
+	nextHop := net.ParseIP(route.NextHop)
+	if nextHop == nil {
+		return fmt.Errorf("invalid route next hop %s", route.NextHop)
+	}
+	if IPVersion(nextHop) != version {
+		return fmt.Errorf("route next hop %s is not an %s address", route.NextHop, version)
+	}
+	return nil
+}
+
+// verifyCtlplaneAddresses - Check the ctlPlaneIPs of a host, at most one per IP version with a gateway of the
+// same version, and that the ctlplaneGateway of the host is of the version of one of them
+func verifyCtlplaneAddresses(compute InstanceSpec) error {
+	if len(compute.CtlPlaneIPs) == 0 {
+		return nil
+	}
+	if compute.CtlPlaneIP != "" {
+		return fmt.Errorf("ctlPlaneIP and ctlPlaneIPs are mutually exclusive")
+	}
+
+	versions := map[string]bool{}
+	for _, address := range compute.CtlPlaneIPs {
+		version := IPVersion(cidrIP(address.IP))
+		if version == "" {
+			return fmt.Errorf("ctlPlaneIPs: %s is not in CIDR notation", address.IP)
+		}
+		if versions[version] {
+			return fmt.Errorf("ctlPlaneIPs: more than one %s address", version)
+		}
+		versions[version] = true
+
+		if address.Gateway != "" && IPVersion(net.ParseIP(address.Gateway)) != version {
+			return fmt.Errorf("ctlPlaneIPs: gateway %s is not an %s address", address.Gateway, version)
+		}
+	}
+
+	if compute.CtlplaneGateway != "" && !versions[IPVersion(net.ParseIP(compute.CtlplaneGateway))] {
+		return fmt.Errorf("ctlplaneGateway %s is not of the IP version of any of ctlPlaneIPs", compute.CtlplaneGateway)
+	}
+	return nil
+}
+
+// verifyCtlplaneRoutes - Check the static ctlplane routes of a host, each needs a ctlplane address of the IP
+// version of its destination to go through, unless addresses is nil
+func verifyCtlplaneRoutes(routes []Route, addresses []CtlplaneAddress) error {
+	for _, route := range routes {
+		if err := verifyRoute(route); err != nil {
+			return fmt.Errorf("ctlplaneRoutes: %w", err)
+		}
+		version := IPVersion(cidrIP(route.Destination))
+		if addresses != nil && !slices.ContainsFunc(addresses, func(a CtlplaneAddress) bool { return IPVersion(cidrIP(a.IP)) == version }) {
+			return fmt.Errorf("ctlplaneRoutes: no %s ctlplane address for the route to %s", version, route.Destination)
+		}
+	}
+	return nil
+}
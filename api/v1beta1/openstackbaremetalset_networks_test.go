@@ -0,0 +1,348 @@
+package v1beta1
+
+import (
+	. "github.com/onsi/ginkgo/v2" //revive:disable:dot-imports
+	. "github.com/onsi/gomega"    //revive:disable:dot-imports
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("BaremetalHostNetworks", func() {
+	var instance *OpenStackBaremetalSet
+
+	BeforeEach(func() {
+		vlan20, vlan21 := 20, 21
+		instance = &OpenStackBaremetalSet{}
+		instance.Spec.Networks = []NetworkSpec{
+			{Name: "internalapi", Vlan: &vlan20, MTU: 1500},
+			{Name: "storage", Vlan: &vlan21, Gateway: "172.18.0.1"},
+		}
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {
+				Networks: []NetworkSpec{
+					{Name: "tenant", Interface: "eth2", IP: "172.19.0.100/24"},
+					{Name: "storage", Interface: "eth1", IP: "172.18.0.100/24"},
+					{Name: "internalapi", IP: "172.17.0.100/24"},
+				},
+			},
+			"compute-1": {},
+		}
+	})
+
+	It("merges the networks of the host into those of the set", func() {
+		vlan20, vlan21 := 20, 21
+		Expect(BaremetalHostNetworks(instance, "compute-0")).To(Equal([]NetworkSpec{
+			{Name: "internalapi", Vlan: &vlan20, IP: "172.17.0.100/24", MTU: 1500},
+			{Name: "storage", Interface: "eth1", Vlan: &vlan21, IP: "172.18.0.100/24", Gateway: "172.18.0.1"},
+			{Name: "tenant", Interface: "eth2", IP: "172.19.0.100/24"},
+		}))
+	})
+
+	It("doesn't change the networks of the set", func() {
+		networks := BaremetalHostNetworks(instance, "compute-1")
+		Expect(networks).To(HaveLen(2))
+		*networks[0].Vlan = 30
+		Expect(*instance.Spec.Networks[0].Vlan).To(Equal(20))
+	})
+})
+
+var _ = Describe("VerifyBaremetalSetNetworks", func() {
+	var instance *OpenStackBaremetalSet
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{}
+		instance.Spec.Networks = []NetworkSpec{
+			{Name: "internalapi", Gateway: "172.17.0.1"},
+		}
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {
+				Networks: []NetworkSpec{
+					{Name: "internalapi", IP: "172.17.0.100/24"},
+					{Name: "storage", IP: "fd00:18::100/64", Routes: []Route{
+						{Destination: "fd00:28::/64", NextHop: "fd00:18::1"},
+					}},
+				},
+			},
+		}
+	})
+
+	It("accepts hosts with an IP on every network", func() {
+		Expect(VerifyBaremetalSetNetworks(instance)).To(Succeed())
+	})
+
+	It("requires an IP per host", func() {
+		instance.Spec.BaremetalHosts["compute-1"] = InstanceSpec{}
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("host compute-1: network internalapi: no ip given"))
+	})
+
+	It("doesn't check the networks of hosts bringing their own networkData", func() {
+		instance.Spec.BaremetalHosts["compute-1"] = InstanceSpec{
+			NetworkData: &corev1.SecretReference{Name: "compute-1-networkdata"},
+		}
+		Expect(VerifyBaremetalSetNetworks(instance)).To(Succeed())
+		Expect(BaremetalHostIPAddresses(instance, "compute-1")).NotTo(HaveKey("internalapi"))
+	})
+
+	It("rejects IPs for the whole set", func() {
+		instance.Spec.Networks[0].IP = "172.17.0.100/24"
+		Expect(VerifyBaremetalSetNetworks(instance)).NotTo(Succeed())
+	})
+
+	It("rejects invalid addresses", func() {
+		instance.Spec.BaremetalHosts["compute-0"].Networks[0].IP = "172.17.0.100"
+		Expect(VerifyBaremetalSetNetworks(instance)).NotTo(Succeed())
+
+		instance.Spec.BaremetalHosts["compute-0"].Networks[0].IP = "172.17.0.100/24"
+		instance.Spec.BaremetalHosts["compute-0"].Networks[1].Routes[0].NextHop = "fd00:18::1/64"
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("invalid route next hop"))
+	})
+
+	It("keeps ctlplane to ctlPlaneIP", func() {
+		instance.Spec.BaremetalHosts["compute-0"] = InstanceSpec{
+			Networks: []NetworkSpec{
+				{Name: "internalapi", IP: "172.17.0.100/24"},
+				{Name: "ctlplane", IP: "192.168.122.100/24"},
+			},
+		}
+		Expect(VerifyBaremetalSetNetworks(instance)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("BaremetalHostCtlplaneAddresses", func() {
+	var instance *OpenStackBaremetalSet
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{}
+		instance.Spec.CtlplaneGateway = "10.0.0.254"
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {CtlPlaneIP: "10.0.0.1/24"},
+			"compute-1": {
+				CtlPlaneIPs: []CtlplaneAddress{
+					{IP: "10.0.0.2/24"},
+					{IP: "fd00:1::2/64", Gateway: "fd00:1::1"},
+				},
+				Networks: []NetworkSpec{{Name: "internalapi", IP: "172.17.0.2/24"}},
+			},
+		}
+	})
+
+	It("uses ctlPlaneIP with the ctlplaneGateway", func() {
+		Expect(BaremetalHostCtlplaneAddresses(instance, "compute-0")).To(Equal([]CtlplaneAddress{
+			{IP: "10.0.0.1/24", Gateway: "10.0.0.254"},
+		}))
+	})
+
+	It("gives the ctlplaneGateway to the ctlPlaneIPs address of its IP version only", func() {
+		Expect(BaremetalHostCtlplaneAddresses(instance, "compute-1")).To(Equal([]CtlplaneAddress{
+			{IP: "10.0.0.2/24", Gateway: "10.0.0.254"},
+			{IP: "fd00:1::2/64", Gateway: "fd00:1::1"},
+		}))
+		Expect(instance.Spec.BaremetalHosts["compute-1"].CtlPlaneIPs[0].Gateway).To(BeEmpty())
+
+		compute := instance.Spec.BaremetalHosts["compute-1"]
+		compute.CtlPlaneIPs = []CtlplaneAddress{{IP: "fd00:1::2/64"}, {IP: "10.0.0.2/24"}}
+		instance.Spec.BaremetalHosts["compute-1"] = compute
+		Expect(BaremetalHostCtlplaneAddresses(instance, "compute-1")).To(Equal([]CtlplaneAddress{
+			{IP: "fd00:1::2/64"},
+			{IP: "10.0.0.2/24", Gateway: "10.0.0.254"},
+		}))
+	})
+
+	It("does not give the ctlplaneGateway to a ctlPlaneIP of the other IP version", func() {
+		instance.Spec.BaremetalHosts["compute-0"] = InstanceSpec{CtlPlaneIP: "fd00:1::1/64"}
+		Expect(BaremetalHostCtlplaneAddresses(instance, "compute-0")).To(Equal([]CtlplaneAddress{
+			{IP: "fd00:1::1/64"},
+		}))
+	})
+
+	It("records every address of the host", func() {
+		Expect(BaremetalHostIPAddresses(instance, "compute-1")).To(Equal(map[string]string{
+			"ctlplane":      "10.0.0.2/24",
+			"ctlplane-ipv6": "fd00:1::2/64",
+			"internalapi":   "172.17.0.2/24",
+		}))
+	})
+})
+
+var _ = Describe("VerifyBaremetalSetNetworks ctlPlaneIPs", func() {
+	var instance *OpenStackBaremetalSet
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{}
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {
+				CtlPlaneIPs: []CtlplaneAddress{
+					{IP: "fd00:1::2/64", Gateway: "fd00:1::1"},
+					{IP: "10.0.0.2/24", Gateway: "10.0.0.254"},
+				},
+			},
+		}
+	})
+
+	It("accepts one address per IP version", func() {
+		Expect(VerifyBaremetalSetNetworks(instance)).To(Succeed())
+	})
+
+	It("rejects two addresses of the same IP version", func() {
+		instance.Spec.BaremetalHosts["compute-0"].CtlPlaneIPs[1].IP = "fd00:2::2/64"
+		instance.Spec.BaremetalHosts["compute-0"].CtlPlaneIPs[1].Gateway = ""
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("more than one ipv6 address"))
+	})
+
+	It("rejects gateways of the other IP version", func() {
+		instance.Spec.BaremetalHosts["compute-0"].CtlPlaneIPs[1].Gateway = "fd00:1::1"
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("gateway fd00:1::1 is not an ipv4 address"))
+	})
+
+	It("rejects a ctlplaneGateway of no IP version of the ctlPlaneIPs", func() {
+		compute := instance.Spec.BaremetalHosts["compute-0"]
+		compute.CtlPlaneIPs = compute.CtlPlaneIPs[:1]
+		compute.CtlplaneGateway = "10.0.0.254"
+		instance.Spec.BaremetalHosts["compute-0"] = compute
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("ctlplaneGateway 10.0.0.254 is not of the IP version of any of ctlPlaneIPs"))
+
+		compute.CtlplaneGateway = "fd00:1::1"
+		instance.Spec.BaremetalHosts["compute-0"] = compute
+		Expect(VerifyBaremetalSetNetworks(instance)).To(Succeed())
+	})
+
+	It("rejects ctlPlaneIP along with ctlPlaneIPs", func() {
+		compute := instance.Spec.BaremetalHosts["compute-0"]
+		compute.CtlPlaneIP = "10.0.0.2/24"
+		instance.Spec.BaremetalHosts["compute-0"] = compute
+		Expect(VerifyBaremetalSetNetworks(instance)).NotTo(Succeed())
+	})
+})
+
+var _ = Describe("VerifyBaremetalSetNetworks ctlplaneRoutes", func() {
+	var instance *OpenStackBaremetalSet
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{}
+		instance.Spec.CtlplaneRoutes = []Route{
+			{Destination: "192.168.124.0/24", NextHop: "192.168.122.1"},
+		}
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {CtlPlaneIP: "192.168.122.100/24"},
+			"compute-1": {
+				CtlPlaneIPs: []CtlplaneAddress{{IP: "192.168.122.101/24"}, {IP: "fd00:122::101/64"}},
+				CtlplaneRoutes: []Route{
+					{Destination: "fd00:124::/64", NextHop: "fd00:122::1"},
+				},
+			},
+		}
+	})
+
+	It("accepts routes through a ctlplane address of their IP version", func() {
+		Expect(VerifyBaremetalSetNetworks(instance)).To(Succeed())
+	})
+
+	It("uses the routes of the host instead of those of the set", func() {
+		Expect(BaremetalHostCtlplaneRoutes(instance, "compute-0")).To(Equal(instance.Spec.CtlplaneRoutes))
+		Expect(BaremetalHostCtlplaneRoutes(instance, "compute-1")).To(Equal([]Route{
+			{Destination: "fd00:124::/64", NextHop: "fd00:122::1"},
+		}))
+	})
+
+	It("rejects routes without a ctlplane address of their IP version", func() {
+		instance.Spec.CtlplaneRoutes = append(instance.Spec.CtlplaneRoutes,
+			Route{Destination: "fd00:125::/64", NextHop: "fd00:122::1"})
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("host compute-0: ctlplaneRoutes: no ipv6 ctlplane address"))
+	})
+
+	It("rejects next hops of the other IP version", func() {
+		instance.Spec.CtlplaneRoutes[0].NextHop = "fd00:122::1"
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("route next hop fd00:122::1 is not an ipv4 address"))
+	})
+
+	It("skips the ctlplane address check for hosts with networkData or without ctlplane address", func() {
+		instance.Spec.CtlplaneRoutes = append(instance.Spec.CtlplaneRoutes,
+			Route{Destination: "fd00:125::/64", NextHop: "fd00:122::1"})
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {},
+			"compute-1": {
+				CtlPlaneIP:  "192.168.122.101/24",
+				NetworkData: &corev1.SecretReference{Name: "compute-1-networkdata"},
+			},
+		}
+		Expect(VerifyBaremetalSetNetworks(instance)).To(Succeed())
+
+		instance.Spec.CtlplaneRoutes[1].NextHop = "192.168.122.1"
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("route next hop 192.168.122.1 is not an ipv6 address"))
+	})
+})
+
+var _ = Describe("VerifyBaremetalSetNetworks bonds", func() {
+	var instance *OpenStackBaremetalSet
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{}
+		instance.Spec.CtlplaneBond = &BondConfig{
+			BondInterfaces: []string{"eno1", "eno2"},
+			BondMode:       "active-backup",
+		}
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {CtlPlaneIP: "10.0.0.1/24"},
+			"compute-1": {
+				CtlPlaneIP: "10.0.0.2/24",
+				CtlplaneBond: &BondConfig{
+					BondInterfaces: []string{"ens1f0", "ens1f1"},
+					BondMode:       "802.3ad",
+					BondOptions:    map[string]string{"lacp_rate": "fast", "bond_xmit_hash_policy": "layer3+4"},
+				},
+			},
+		}
+	})
+
+	It("accepts kernel bonding modes and options", func() {
+		Expect(VerifyBaremetalSetNetworks(instance)).To(Succeed())
+	})
+
+	It("uses the bond of the host instead of the one of the set", func() {
+		Expect(BaremetalHostCtlplaneBond(instance, "compute-0").BondInterfaces).To(Equal([]string{"eno1", "eno2"}))
+		Expect(BaremetalHostCtlplaneBond(instance, "compute-1").BondInterfaces).To(Equal([]string{"ens1f0", "ens1f1"}))
+	})
+
+	It("rejects other bonding modes", func() {
+		instance.Spec.CtlplaneBond.BondMode = "lacp"
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("ctlplaneBond: invalid bondMode \"lacp\""))
+	})
+
+	It("rejects unknown options and the mode as an option", func() {
+		instance.Spec.BaremetalHosts["compute-1"].CtlplaneBond.BondOptions["bond_mode"] = "balance-rr"
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("host compute-1: ctlplaneBond: invalid bondOptions key \"bond_mode\""))
+	})
+
+	It("checks the bonds of the networks", func() {
+		instance.Spec.BaremetalHosts["compute-0"] = InstanceSpec{
+			CtlPlaneIP: "10.0.0.1/24",
+			Networks: []NetworkSpec{{
+				Name: "tenant",
+				IP:   "172.19.0.100/24",
+				Bond: &BondConfig{BondInterfaces: []string{"eth2", "eth3"}, BondMode: "round-robin"},
+			}},
+		}
+		err := VerifyBaremetalSetNetworks(instance)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("host compute-0: network tenant: bond: invalid bondMode"))
+	})
+})
@@ -0,0 +1,87 @@
+package v1beta1
+
+import (
+	"math"
+	"sort"
+
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+// PreferredBmhLabel - Label placed on BaremetalHosts to have them allocated before other BaremetalHosts of
+// the same size
+const PreferredBmhLabel = "baremetal.openstack.org/preferred"
+
+// BmhScore - The rank of a BaremetalHost for a new host, compared element by element. Lower scores are
+// preferred
+type BmhScore []int64
+
+// Less - Whether the score ranks before the other one
+func (s BmhScore) Less(other BmhScore) bool {
+	for i := 0; i < len(s) && i < len(other); i++ {
+		if s[i] != other[i] {
+			return s[i] < other[i]
+		}
+	}
+	return len(s) < len(other)
+}
+
+// BmhScorer - Ranks the BaremetalHosts that can be allocated to the new hosts of an OpenStackBaremetalSet.
+// BaremetalHosts with equal scores are ordered by name
+type BmhScorer interface {
+	Score(instance *OpenStackBaremetalSet, bmh *metal3v1.BareMetalHost) BmhScore
+}
+
+// BinPackingBmhScorer - The default BmhScorer. It prefers the BaremetalHosts with the disks preferred by
+// the diskReqs of the most new hosts, then the smallest BaremetalHosts, by memory, then CPU count, then
+// total storage, so that bigger ones remain for the sets asking for them. Among BaremetalHosts of the same
+// size, those labeled with PreferredBmhLabel come first
+type BinPackingBmhScorer struct{}
+
+// Score - implements BmhScorer
+func (BinPackingBmhScorer) Score(instance *OpenStackBaremetalSet, bmh *metal3v1.BareMetalHost) BmhScore {
+	preferred := int64(1)
+	if _, ok := bmh.Labels[PreferredBmhLabel]; ok {
+		preferred = 0
+	}
+
+	hardware := bmh.Status.HardwareDetails
+	if hardware == nil {
+		// Not inspected, so it could be of any size
+		return BmhScore{math.MaxInt64, math.MaxInt64, math.MaxInt64, math.MaxInt64, preferred}
+	}
+
+	// The new hosts preferring disks the BMH lacks
+	missingDisks := int64(0)
+	for hostName, compute := range instance.Spec.BaremetalHosts {
+		if _, found := instance.Status.BaremetalHosts[hostName]; found || compute.AdoptBmh != "" {
+			continue
+		}
+		diskReqs := instance.Spec.HardwareReqs.Merge(compute.HardwareReqs).DiskReqs
+		if diskReqs.NVMeReq.NVMe && !hasPreferredDisks(diskReqs, bmh) {
+			missingDisks++
+		}
+	}
+
+	storageBytes := int64(0)
+	for _, disk := range hardware.Storage {
+		storageBytes += int64(disk.SizeBytes)
+	}
+
+	return BmhScore{missingDisks, int64(hardware.RAMMebibytes), int64(hardware.CPU.Count), storageBytes, preferred}
+}
+
+// sortBaremetalHostsByScore - Order the BMHs from the most to the least preferred for a new host of the set
+func sortBaremetalHostsByScore(scorer BmhScorer, instance *OpenStackBaremetalSet, bmhs []metal3v1.BareMetalHost) {
+	scores := make(map[string]BmhScore, len(bmhs))
+	for i := range bmhs {
+		scores[bmhs[i].Name] = scorer.Score(instance, &bmhs[i])
+	}
+
+	sort.SliceStable(bmhs, func(i, j int) bool {
+		scoreI, scoreJ := scores[bmhs[i].Name], scores[bmhs[j].Name]
+		if scoreI.Less(scoreJ) || scoreJ.Less(scoreI) {
+			return scoreI.Less(scoreJ)
+		}
+		return bmhs[i].Name < bmhs[j].Name
+	})
+}
@@ -0,0 +1,179 @@
+package v1beta1
+
+import (
+	"github.com/go-logr/logr"
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo/v2" //revive:disable:dot-imports
+	. "github.com/onsi/gomega"    //revive:disable:dot-imports
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// bmhWithHardware - An available BMH with the given memory, CPU count and single disk size
+func bmhWithHardware(name string, ramGb int, cpus int, diskGb int64, labels map[string]string) metal3v1.BareMetalHost {
+	return metal3v1.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Status: metal3v1.BareMetalHostStatus{
+			Provisioning: metal3v1.ProvisionStatus{
+				State: metal3v1.StateAvailable,
+			},
+			HardwareDetails: &metal3v1.HardwareDetails{
+				RAMMebibytes: ramGb * 1024,
+				CPU: metal3v1.CPU{
+					Count: cpus,
+				},
+				Storage: []metal3v1.Storage{
+					{SizeBytes: metal3v1.Capacity(diskGb * 1073741824)},
+				},
+			},
+		},
+	}
+}
+
+// reverseNameScorer - Prefers BMHs with names sorting last
+type reverseNameScorer struct{}
+
+func (reverseNameScorer) Score(_ *OpenStackBaremetalSet, bmh *metal3v1.BareMetalHost) BmhScore {
+	score := BmhScore{}
+	for _, c := range bmh.Name {
+		score = append(score, -int64(c))
+	}
+	return score
+}
+
+func bmhNames(bmhs []metal3v1.BareMetalHost) []string {
+	names := []string{}
+	for _, bmh := range bmhs {
+		names = append(names, bmh.Name)
+	}
+	return names
+}
+
+var _ = Describe("BmhScore", func() {
+	It("compares element by element", func() {
+		Expect(BmhScore{1, 5}.Less(BmhScore{2, 0})).To(BeTrue())
+		Expect(BmhScore{2, 0}.Less(BmhScore{1, 5})).To(BeFalse())
+		Expect(BmhScore{1, 5}.Less(BmhScore{1, 6})).To(BeTrue())
+		Expect(BmhScore{1, 5}.Less(BmhScore{1, 5})).To(BeFalse())
+		Expect(BmhScore{1}.Less(BmhScore{1, 0})).To(BeTrue())
+	})
+})
+
+var _ = Describe("BinPackingBmhScorer", func() {
+	var instance *OpenStackBaremetalSet
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{}
+	})
+
+	It("prefers the BMH with the least memory", func() {
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithHardware("big", 2048, 64, 1000, nil),
+			bmhWithHardware("small", 128, 64, 1000, nil),
+		}
+		sortBaremetalHostsByScore(BinPackingBmhScorer{}, instance, bmhs)
+		Expect(bmhNames(bmhs)).To(Equal([]string{"small", "big"}))
+	})
+
+	It("prefers fewer CPUs, then less storage, on equal memory", func() {
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithHardware("many-cpus", 128, 64, 100, nil),
+			bmhWithHardware("big-disk", 128, 32, 4000, nil),
+			bmhWithHardware("small-disk", 128, 32, 100, nil),
+		}
+		sortBaremetalHostsByScore(BinPackingBmhScorer{}, instance, bmhs)
+		Expect(bmhNames(bmhs)).To(Equal([]string{"small-disk", "big-disk", "many-cpus"}))
+	})
+
+	It("prefers the preferred label only among BMHs of the same size", func() {
+		preferred := map[string]string{PreferredBmhLabel: "true"}
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithHardware("a-plain", 128, 32, 100, nil),
+			bmhWithHardware("b-preferred", 128, 32, 100, preferred),
+			bmhWithHardware("c-preferred-big", 256, 32, 100, preferred),
+		}
+		sortBaremetalHostsByScore(BinPackingBmhScorer{}, instance, bmhs)
+		Expect(bmhNames(bmhs)).To(Equal([]string{"b-preferred", "a-plain", "c-preferred-big"}))
+	})
+
+	It("prefers the BMHs with the disks preferred by the new hosts over smaller ones", func() {
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{"compute-0": {}, "compute-1": {}}
+		instance.Spec.HardwareReqs.DiskReqs.NVMeReq.NVMe = true
+		nvme := bmhWithHardware("big-nvme", 256, 32, 100, nil)
+		nvme.Status.HardwareDetails.Storage[0].Type = metal3v1.NVME
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithHardware("small", 128, 32, 100, nil),
+			nvme,
+		}
+		sortBaremetalHostsByScore(BinPackingBmhScorer{}, instance, bmhs)
+		Expect(bmhNames(bmhs)).To(Equal([]string{"big-nvme", "small"}))
+
+		// Only the hosts still to be allocated count
+		instance.Status.BaremetalHosts = map[string]HostStatus{"compute-0": {}, "compute-1": {}}
+		sortBaremetalHostsByScore(BinPackingBmhScorer{}, instance, bmhs)
+		Expect(bmhNames(bmhs)).To(Equal([]string{"small", "big-nvme"}))
+	})
+
+	It("ranks BMHs that were not inspected last", func() {
+		uninspected := bmhWithHardware("a-uninspected", 0, 0, 0, nil)
+		uninspected.Status.HardwareDetails = nil
+		bmhs := []metal3v1.BareMetalHost{
+			uninspected,
+			bmhWithHardware("b-huge", 4096, 256, 10000, nil),
+		}
+		sortBaremetalHostsByScore(BinPackingBmhScorer{}, instance, bmhs)
+		Expect(bmhNames(bmhs)).To(Equal([]string{"b-huge", "a-uninspected"}))
+	})
+
+	It("orders BMHs with equal scores by name", func() {
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithHardware("compute-c", 128, 32, 100, nil),
+			bmhWithHardware("compute-a", 128, 32, 100, nil),
+			bmhWithHardware("compute-b", 128, 32, 100, nil),
+		}
+		sortBaremetalHostsByScore(BinPackingBmhScorer{}, instance, bmhs)
+		Expect(bmhNames(bmhs)).To(Equal([]string{"compute-a", "compute-b", "compute-c"}))
+	})
+})
+
+var _ = Describe("VerifyBaremetalSetScaleUp BMH scoring", func() {
+	var instance *OpenStackBaremetalSet
+	var allBmhs *metal3v1.BareMetalHostList
+
+	BeforeEach(func() {
+		instance = &OpenStackBaremetalSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "compute",
+				Namespace: "openstack",
+			},
+		}
+		instance.Spec.BmhNamespace = "openstack"
+		instance.Spec.BaremetalHosts = map[string]InstanceSpec{
+			"compute-0": {CtlPlaneIP: "10.0.0.1/24"},
+		}
+		instance.Spec.HardwareReqs.MemReqs.GbReq.Gb = 64
+
+		allBmhs = &metal3v1.BareMetalHostList{
+			Items: []metal3v1.BareMetalHost{
+				bmhWithHardware("huge", 2048, 128, 1000, nil),
+				bmhWithHardware("too-small", 32, 16, 1000, nil),
+				bmhWithHardware("fitting", 128, 32, 1000, nil),
+			},
+		}
+	})
+
+	It("allocates the smallest BMH meeting the hardwareReqs", func() {
+		selected, err := VerifyBaremetalSetScaleUp(logr.Discard(), BinPackingBmhScorer{}, instance, allBmhs, &metal3v1.BareMetalHostList{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected).To(HaveKey("compute-0"))
+		Expect(selected["compute-0"].Name).To(Equal("fitting"))
+	})
+
+	It("uses the given BmhScorer", func() {
+		selected, err := VerifyBaremetalSetScaleUp(logr.Discard(), reverseNameScorer{}, instance, allBmhs, &metal3v1.BareMetalHostList{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected["compute-0"].Name).To(Equal("huge"))
+	})
+})
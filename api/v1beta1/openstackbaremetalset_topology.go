@@ -0,0 +1,186 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+// topologySpread - Track how the hosts of a set are spread across the domains of its
+// topologySpreadConstraints while new hosts get assigned BMHs
+type topologySpread struct {
+	constraints []TopologySpreadConstraint
+	// hosts - Number of hosts per domain, per topologyKey
+	hosts map[string]map[string]int
+	// free - Number of not yet assigned BMHs per domain, per topologyKey
+	free map[string]map[string]int
+	// maxSkew - The skew allowed per topologyKey: the maxSkew of the constraint, or the skew of the hosts the
+	// set already has if it is higher, e.g. after a scale-down, so that scaling up can't make it any worse
+	maxSkew map[string]int
+}
+
+// newTopologySpread - Start from the hosts the set already has, in the domains of both those
+// and the BMHs still available for new hosts
+func newTopologySpread(
+	constraints []TopologySpreadConstraint,
+	availableBmhs []metal3v1.BareMetalHost,
+	existingBmhs []metal3v1.BareMetalHost,
+) *topologySpread {
+	spread := &topologySpread{
+		constraints: constraints,
+		hosts:       map[string]map[string]int{},
+		free:        map[string]map[string]int{},
+		maxSkew:     map[string]int{},
+	}
+
+	for _, constraint := range constraints {
+		key := constraint.TopologyKey
+		spread.hosts[key] = map[string]int{}
+		spread.free[key] = map[string]int{}
+
+		for _, bmh := range availableBmhs {
+			if domain, ok := bmh.Labels[key]; ok {
+				spread.hosts[key][domain] += 0
+				spread.free[key][domain]++
+			}
+		}
+		for _, bmh := range existingBmhs {
+			if domain, ok := bmh.Labels[key]; ok {
+				spread.hosts[key][domain]++
+			}
+		}
+
+		minHosts, maxHosts := minMax(spread.hosts[key])
+		spread.maxSkew[key] = max(constraint.MaxSkew, maxHosts-minHosts)
+	}
+
+	return spread
+}
+
+// add - Account for a BMH being assigned to (delta 1) or unassigned from (delta -1) a new host
+func (t *topologySpread) add(bmh *metal3v1.BareMetalHost, delta int) {
+	for _, constraint := range t.constraints {
+		domain := bmh.Labels[constraint.TopologyKey]
+		t.hosts[constraint.TopologyKey][domain] += delta
+		t.free[constraint.TopologyKey][domain] -= delta
+	}
+}
+
+// satisfied - Whether the hosts are spread within the allowed skew of every constraint
+func (t *topologySpread) satisfied() bool {
+	for _, constraint := range t.constraints {
+		minHosts, maxHosts := minMax(t.hosts[constraint.TopologyKey])
+		if maxHosts-minHosts > t.maxSkew[constraint.TopologyKey] {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiedBy - Whether the hosts would be spread within the allowed skew of every constraint with the BMHs
+// of an assignment of the new hosts
+func (t *topologySpread) satisfiedBy(assignment map[string]metal3v1.BareMetalHost) bool {
+	for _, bmh := range assignment {
+		t.add(&bmh, 1)
+	}
+	satisfied := t.satisfied()
+	for _, bmh := range assignment {
+		t.add(&bmh, -1)
+	}
+	return satisfied
+}
+
+// feasible - Whether assigning the remaining new hosts can still bring every constraint within its
+// allowed skew. Domains only ever gain hosts, so the skew can't get lower than the current most populated
+// domain against the least populated one once it got all the hosts it still could
+func (t *topologySpread) feasible(remaining int) bool {
+	for _, constraint := range t.constraints {
+		hosts := t.hosts[constraint.TopologyKey]
+		_, maxHosts := minMax(hosts)
+
+		for domain, count := range hosts {
+			reachable := count + min(remaining, t.free[constraint.TopologyKey][domain])
+			if maxHosts-reachable > t.maxSkew[constraint.TopologyKey] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// leastPopulatedFirst - The indexes in bmhs of the candidate BMHs, ordered by how many hosts their
+// domains already have
+func (t *topologySpread) leastPopulatedFirst(bmhs []metal3v1.BareMetalHost, candidates []int) []int {
+	population := make(map[int]int, len(candidates))
+	for _, i := range candidates {
+		for _, constraint := range t.constraints {
+			population[i] += t.hosts[constraint.TopologyKey][bmhs[i].Labels[constraint.TopologyKey]]
+		}
+	}
+
+	sorted := slices.Clone(candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return population[sorted[i]] < population[sorted[j]]
+	})
+
+	return sorted
+}
+
+// String - The hosts and free BMHs per domain of every constraint, e.g.
+// "topologyKey rack, maxSkew 1: r1=2/0, r2=0/3"
+func (t *topologySpread) String() string {
+	descriptions := []string{}
+
+	for _, constraint := range t.constraints {
+		domains := []string{}
+		for domain, count := range t.hosts[constraint.TopologyKey] {
+			domains = append(domains, fmt.Sprintf("%s=%d/%d", domain, count, t.free[constraint.TopologyKey][domain]))
+		}
+		sort.Strings(domains)
+
+		maxSkew := fmt.Sprintf("%d", constraint.MaxSkew)
+		if allowed := t.maxSkew[constraint.TopologyKey]; allowed > constraint.MaxSkew {
+			maxSkew = fmt.Sprintf("%s (%d for the existing hosts)", maxSkew, allowed)
+		}
+
+		descriptions = append(descriptions, fmt.Sprintf("topologyKey %s, maxSkew %s, hosts/free BaremetalHosts per domain: %s",
+			constraint.TopologyKey, maxSkew, strings.Join(domains, ", ")))
+	}
+
+	return strings.Join(descriptions, "; ")
+}
+
+// minMax - The lowest and highest values of a map, 0 for both if it is empty
+func minMax(counts map[string]int) (int, int) {
+	first := true
+	minCount, maxCount := 0, 0
+	for _, count := range counts {
+		if first || count < minCount {
+			minCount = count
+		}
+		if first || count > maxCount {
+			maxCount = count
+		}
+		first = false
+	}
+	return minCount, maxCount
+}
@@ -0,0 +1,169 @@
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	. "github.com/onsi/ginkgo/v2" //revive:disable:dot-imports
+	. "github.com/onsi/gomega"    //revive:disable:dot-imports
+)
+
+// rackSpread - A topology spread over the rack label with the given maxSkew, from the hosts of the existing BMHs
+func rackSpread(maxSkew int, bmhs []metal3v1.BareMetalHost, existing []metal3v1.BareMetalHost) *topologySpread {
+	constraints := []TopologySpreadConstraint{{TopologyKey: "rack", MaxSkew: maxSkew}}
+	return newTopologySpread(constraints, bmhs, existing)
+}
+
+var _ = Describe("findSpreadBaremetalSetInstanceLabelAssignments", func() {
+	It("spreads the computes across the domains", func() {
+		computes := map[string]InstanceSpec{
+			"compute-0": {},
+			"compute-1": {},
+		}
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithLabels("bmh-0", map[string]string{"rack": "r1"}),
+			bmhWithLabels("bmh-1", map[string]string{"rack": "r1"}),
+			bmhWithLabels("bmh-2", map[string]string{"rack": "r2"}),
+		}
+
+		assignment, err := findSpreadBaremetalSetInstanceLabelAssignments(computes, bmhs, rackSpread(0, bmhs, nil), maxSpreadSearchSteps)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignment).To(HaveLen(2))
+		Expect([]string{assignment["compute-0"].Labels["rack"], assignment["compute-1"].Labels["rack"]}).To(ConsistOf("r1", "r2"))
+	})
+
+	It("tells apart BMHs with the same labels that satisfy different hardwareReqs", func() {
+		bigReqs := &HardwareReqs{}
+		bigReqs.MemReqs.GbReq.Gb = 64
+		computes := map[string]InstanceSpec{
+			"compute-0": {BmhLabelSelector: map[string]string{"rack": "r2"}},
+			"compute-1": {BmhLabelSelector: map[string]string{"rack": "r1"}},
+			"compute-2": {HardwareReqs: bigReqs},
+		}
+		// compute-1 first tries big-r1, which compute-2 needs, then must still try small-r1
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithHardware("big-r1", 128, 32, 1000, map[string]string{"rack": "r1"}),
+			bmhWithHardware("small-r1", 32, 16, 1000, map[string]string{"rack": "r1"}),
+			bmhWithHardware("big-r2", 128, 32, 1000, map[string]string{"rack": "r2"}),
+		}
+
+		assignment, err := findSpreadBaremetalSetInstanceLabelAssignments(computes, bmhs, rackSpread(1, bmhs, nil), maxSpreadSearchSteps)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignment).To(HaveLen(3))
+		Expect(assignment["compute-1"].Name).To(Equal("small-r1"))
+		Expect(assignment["compute-2"].Name).To(Equal("big-r1"))
+	})
+
+	It("returns nil when the computes can't be spread", func() {
+		computes := map[string]InstanceSpec{
+			"compute-0": {BmhLabelSelector: map[string]string{"rack": "r1"}},
+			"compute-1": {BmhLabelSelector: map[string]string{"rack": "r1"}},
+		}
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithLabels("bmh-0", map[string]string{"rack": "r1"}),
+			bmhWithLabels("bmh-1", map[string]string{"rack": "r1"}),
+			bmhWithLabels("bmh-2", map[string]string{"rack": "r2"}),
+		}
+
+		assignment, err := findSpreadBaremetalSetInstanceLabelAssignments(computes, bmhs, rackSpread(1, bmhs, nil), maxSpreadSearchSteps)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignment).To(BeNil())
+	})
+
+	It("scales up a set already spread beyond maxSkew without making it worse", func() {
+		// r1 got 3 hosts and r2 none, e.g. after a scale-down
+		existing := []metal3v1.BareMetalHost{}
+		for i := range 3 {
+			existing = append(existing, bmhWithLabels(fmt.Sprintf("used-%d", i), map[string]string{"rack": "r1"}))
+		}
+		bmhs := []metal3v1.BareMetalHost{
+			bmhWithLabels("bmh-0", map[string]string{"rack": "r1"}),
+			bmhWithLabels("bmh-1", map[string]string{"rack": "r1"}),
+		}
+		for i := range 5 {
+			bmhs = append(bmhs, bmhWithLabels(fmt.Sprintf("bmh-r2-%d", i), map[string]string{"rack": "r2"}))
+		}
+
+		computes := map[string]InstanceSpec{"compute-3": {}}
+		assignment, err := findSpreadBaremetalSetInstanceLabelAssignments(computes, bmhs, rackSpread(1, bmhs, existing), maxSpreadSearchSteps)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignment).To(HaveLen(1))
+		Expect(assignment["compute-3"].Labels["rack"]).To(Equal("r2"))
+
+		// Another host in r1 would skew the set further
+		computes = map[string]InstanceSpec{"compute-3": {BmhLabelSelector: map[string]string{"rack": "r1"}}}
+		assignment, err = findSpreadBaremetalSetInstanceLabelAssignments(computes, bmhs, rackSpread(1, bmhs, existing), maxSpreadSearchSteps)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignment).To(BeNil())
+	})
+
+	It("gives up after looking at maxSteps candidates", func() {
+		// One BMH short, so that there is no assignment to find
+		computes, bmhs := rackedComputesAndBmhs(100, 99, 10)
+
+		assignment, err := findSpreadBaremetalSetInstanceLabelAssignments(computes, bmhs, rackSpread(1, bmhs, nil), 1000)
+		Expect(errors.Is(err, errSpreadSearchExhausted)).To(BeTrue())
+		Expect(assignment).To(BeNil())
+	})
+
+	It("spreads 1000 computes over 1000 BMHs", func() {
+		computes, bmhs := rackedComputesAndBmhs(1000, 1000, 10)
+
+		assignment, err := findSpreadBaremetalSetInstanceLabelAssignments(computes, bmhs, rackSpread(1, bmhs, nil), maxSpreadSearchSteps)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(assignment).To(HaveLen(1000))
+	})
+})
+
+func BenchmarkFindSpreadBaremetalSetInstanceLabelAssignments(b *testing.B) {
+	for _, size := range []struct {
+		computes int
+		bmhs     int
+		racks    int
+	}{
+		{100, 100, 10},
+		{1000, 1000, 10},
+		// One BMH short, so that the search runs until it gives up
+		{1000, 999, 10},
+	} {
+		b.Run(fmt.Sprintf("%dx%d/%d-racks", size.computes, size.bmhs, size.racks), func(b *testing.B) {
+			computes, bmhs := rackedComputesAndBmhs(size.computes, size.bmhs, size.racks)
+			b.ResetTimer()
+			for range b.N {
+				_, _ = findSpreadBaremetalSetInstanceLabelAssignments(computes, bmhs, rackSpread(1, bmhs, nil), maxSpreadSearchSteps)
+			}
+		})
+	}
+}
+
+func BenchmarkVerifyBaremetalSetScaleUpTopologySpread(b *testing.B) {
+	for _, size := range []struct {
+		computes int
+		bmhs     int
+		racks    int
+		maxSkew  int
+	}{
+		{1000, 1000, 10, 1},
+		{1000, 1000, 100, 1},
+		// Spare BMHs, so that the hosts can be spread unevenly
+		{1000, 1100, 10, 0},
+		// One BMH short, so that the scale-up fails
+		{1000, 999, 10, 1},
+	} {
+		b.Run(fmt.Sprintf("%dx%d/%d-racks/maxSkew-%d", size.computes, size.bmhs, size.racks, size.maxSkew), func(b *testing.B) {
+			computes, bmhs := rackedComputesAndBmhs(size.computes, size.bmhs, size.racks)
+			instance := &OpenStackBaremetalSet{}
+			instance.Spec.BmhNamespace = "openstack"
+			instance.Spec.BaremetalHosts = computes
+			instance.Spec.TopologySpreadConstraints = []TopologySpreadConstraint{{TopologyKey: "rack", MaxSkew: size.maxSkew}}
+			allBmhs := &metal3v1.BareMetalHostList{Items: bmhs}
+			b.ResetTimer()
+			for range b.N {
+				_, _ = VerifyBaremetalSetScaleUp(logr.Discard(), BinPackingBmhScorer{}, instance, allBmhs, &metal3v1.BareMetalHostList{})
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package v1beta1
 
 import (
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	condition "github.com/openstack-k8s-operators/lib-common/modules/common/condition"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -35,10 +36,77 @@ type BondConfig struct {
 	// BondMode - Bonding mode (e.g., active-backup, 802.3ad)
 	BondMode string `json:"bondMode,omitempty"`
 	// +kubebuilder:validation:Optional
-	// BondOptions - Additional bonding options as key-value pairs
+	// BondOptions - Additional bonding options as key-value pairs, keyed by kernel bonding option name
+	// (e.g. miimon, xmit_hash_policy), with or without the bond_ prefix
 	BondOptions map[string]string `json:"bondOptions,omitempty"`
 }
 
+// BondModes - The kernel bonding modes
+var BondModes = []string{
+	"balance-rr", "active-backup", "balance-xor", "broadcast", "802.3ad", "balance-tlb", "balance-alb",
+}
+
+// BondOptionPrefix - Prefix of the bonding options in the links of network_data.json
+const BondOptionPrefix = "bond_"
+
+// BondOptionNames - The kernel bonding options bondOptions accept, the mode and the member interfaces
+// being set through bondMode and bondInterfaces
+var BondOptionNames = []string{
+	"ad_actor_sys_prio", "ad_actor_system", "ad_select", "ad_user_port_key", "all_slaves_active",
+	"arp_all_targets", "arp_interval", "arp_ip_target", "arp_missed_max", "arp_validate", "downdelay",
+	"fail_over_mac", "lacp_active", "lacp_rate", "lp_interval", "miimon", "min_links", "num_grat_arp",
+	"num_unsol_na", "packets_per_slave", "peer_notif_delay", "primary", "primary_reselect", "resend_igmp",
+	"tlb_dynamic_lb", "updelay", "use_carrier", "xmit_hash_policy",
+}
+
+// CtlplaneAddress defines an address of a host on the ctlplane network
+type CtlplaneAddress struct {
+	// IP - IP in CIDR notation
+	IP string `json:"ip"`
+	// +kubebuilder:validation:Optional
+	// Gateway - IP of the gateway of the address family, ctlplaneGateway by default if of the same family
+	Gateway string `json:"gateway,omitempty"`
+}
+
+// Route defines a static route
+type Route struct {
+	// Destination - Destination network in CIDR notation
+	Destination string `json:"destination"`
+	// NextHop - IP of the gateway to the destination network
+	NextHop string `json:"nextHop"`
+}
+
+// NetworkSpec defines a network, besides ctlplane, configured on the provisioned nodes from first boot
+type NetworkSpec struct {
+	// Name - Name of the network (e.g. internalapi), its IP is recorded under it in the host status ipAddresses
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	// Interface - Interface (or bond) on the provisioned nodes carrying the network, the ctlplane one if not set
+	Interface string `json:"interface,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Bond - Bonding configuration of interface, when it is a bond other than the ctlplane one
+	Bond *BondConfig `json:"bond,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4094
+	// Vlan - Vlan of the network on interface, untagged if not set
+	Vlan *int `json:"vlan,omitempty"`
+	// +kubebuilder:validation:Optional
+	// IP - IP of the host on the network in CIDR notation, only given in the networks of baremetalHosts
+	IP string `json:"ip,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Gateway - IP of the gateway of the network, used for the default route
+	Gateway string `json:"gateway,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Routes - Static routes through the network
+	Routes []Route `json:"routes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=68
+	// MTU - MTU of the network link. The interface or bond it is a VLAN of, ctlplane's included, gets at
+	// least this MTU
+	MTU int `json:"mtu,omitempty"`
+}
+
 // OSImageDeploymentType specifies the type of OS image deployment
 // +kubebuilder:validation:Enum=SelfExtracting;PassThrough
 type OSImageDeploymentType string
@@ -49,8 +117,21 @@ type InstanceSpec struct {
 	// BmhLabelSelector allows for the selection of a particular BaremetalHost based on arbitrary labels
 	BmhLabelSelector map[string]string `json:"bmhLabelSelector,omitempty"`
 	// +kubebuilder:validation:Optional
+	// BmhMatchExpressions - Label selector requirements (In, NotIn, Exists, DoesNotExist) the BaremetalHost
+	// must satisfy on top of bmhLabelSelector
+	BmhMatchExpressions []metav1.LabelSelectorRequirement `json:"bmhMatchExpressions,omitempty"`
+	// +kubebuilder:validation:Optional
+	// BmhName - Name of the BaremetalHost this host must be allocated. Unlike bmhLabelSelector it pins the
+	// host to exactly one BaremetalHost, which must be free and match the set's bmhLabelSelector and hardwareReqs
+	BmhName string `json:"bmhName,omitempty"`
+	// +kubebuilder:validation:Optional
 	// CtlPlaneIP - Control Plane IP in CIDR notation
 	CtlPlaneIP string `json:"ctlPlaneIP,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=2
+	// CtlPlaneIPs - Control Plane IPs of a dual-stack host, one IPv4 and one IPv6, instead of ctlPlaneIP. The
+	// first one is recorded as ctlplane in the host status ipAddresses, the other under ctlplane-ipv4 or ctlplane-ipv6
+	CtlPlaneIPs []CtlplaneAddress `json:"ctlPlaneIPs,omitempty"`
 	// CtlplaneGateway - IP of gateway for ctrlplane network (TODO: acquire this is another manner?)
 	// +kubebuilder:validation:Optional
 	CtlplaneGateway string `json:"ctlplaneGateway,omitempty"`
@@ -58,15 +139,44 @@ type InstanceSpec struct {
 	// +kubebuilder:validation:Optional
 	CtlplaneInterface string `json:"ctlplaneInterface,omitempty"`
 	// +kubebuilder:validation:Optional
+	// CtlplaneBond - Bonding configuration for ctlplane network of this host, replacing the one of the set,
+	// e.g. for hosts naming their NICs differently
+	CtlplaneBond *BondConfig `json:"ctlplaneBond,omitempty"`
+	// +kubebuilder:validation:Optional
 	// CtlplaneVlan - Vlan for ctlplane network
 	CtlplaneVlan *int `json:"ctlplaneVlan,omitempty"`
 	// +kubebuilder:validation:Optional
+	// CtlplaneRoutes - Static routes through the ctlplane network, replacing those of the set. Each goes through
+	// the ctlplane address of the IP version of its destination
+	CtlplaneRoutes []Route `json:"ctlplaneRoutes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=68
+	// CtlplaneMTU - MTU of the ctlplane links, replacing the one of the set
+	CtlplaneMTU int `json:"ctlplaneMtu,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	// Networks - Networks of the host besides ctlplane. Those named as networks of the set give this host's
+	// ip and replace what else they set, the others are configured on this host only
+	Networks []NetworkSpec `json:"networks,omitempty"`
+	// +kubebuilder:validation:Optional
 	// UserData - Host User Data
 	UserData *corev1.SecretReference `json:"userData,omitempty"`
 	// +kubebuilder:validation:Optional
 	// NetworkData - Host Network Data
 	NetworkData *corev1.SecretReference `json:"networkData,omitempty"`
 	// +kubebuilder:validation:Optional
+	// AdoptBmh - Name of an already provisioned (or externally provisioned) BaremetalHost to adopt for this
+	// host. It is taken over as is, keeping its image, userData and networkData, instead of allocating and
+	// provisioning a free BaremetalHost
+	AdoptBmh string `json:"adoptBmh,omitempty"`
+	// +kubebuilder:validation:Optional
+	// HardwareReqs - Hardware requests of this host. Every request set here replaces the same request of the
+	// set's hardwareReqs, the others still apply
+	HardwareReqs *HardwareReqs `json:"hardwareReqs,omitempty"`
+	// +kubebuilder:validation:Optional
+	// RootDeviceHints - Hints for the disk the OS of this host gets installed on, replacing those of the set
+	RootDeviceHints *metal3v1.RootDeviceHints `json:"rootDeviceHints,omitempty"`
 }
 
 // Allowed automated cleaning modes
@@ -81,6 +191,19 @@ const (
 	OSImageDeploymentTypePassThrough    OSImageDeploymentType = "PassThrough"
 )
 
+// SupportedCPUArchs - The CPU architectures hardwareReqs and archImages accept
+var SupportedCPUArchs = []string{"x86_64", "ppc64le", "aarch64"}
+
+// ArchImage - The OS image of the BaremetalHosts of one CPU architecture
+type ArchImage struct {
+	// +kubebuilder:validation:Optional
+	// OSImage - OS qcow2 image Name, the set's osImage if not set. Ignored when osImageDeploymentType is PassThrough.
+	OSImage string `json:"osImage,omitempty"`
+	// +kubebuilder:validation:Optional
+	// OSContainerImageURL - Same as the set's osContainerImageUrl, for this architecture
+	OSContainerImageURL string `json:"osContainerImageUrl,omitempty"`
+}
+
 type OpenStackBaremetalSetTemplateSpec struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=edpm-hardened-uefi.qcow2
@@ -90,6 +213,11 @@ type OpenStackBaremetalSetTemplateSpec struct {
 	// OSContainerImageURL - When osImageDeploymentType is SelfExtracting, container image URL for init with the OS qcow2 image (osImage). When osImageDeploymentType is PassThrough this can be any image URL which the underlying Metal3 instance supports.
 	OSContainerImageURL string `json:"osContainerImageUrl,omitempty"`
 	// +kubebuilder:validation:Optional
+	// ArchImages - OS images per CPU architecture (x86_64, ppc64le or aarch64), used instead of osImage and
+	// osContainerImageUrl for the BaremetalHosts whose inspected CPU arch matches. When osImageDeploymentType is
+	// SelfExtracting, an OpenStackProvisionServer is created for each of them
+	ArchImages map[string]ArchImage `json:"archImages,omitempty"`
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=SelfExtracting
 	// OSImageDeploymentType - Whether the OS image deployment is self-extracting or pass-through based
 	OSImageDeploymentType OSImageDeploymentType `json:"osImageDeploymentType"`
@@ -128,6 +256,10 @@ type OpenStackBaremetalSetTemplateSpec struct {
 	// BmhLabelSelector allows for a sub-selection of BaremetalHosts based on arbitrary labels
 	BmhLabelSelector map[string]string `json:"bmhLabelSelector,omitempty"`
 	// +kubebuilder:validation:Optional
+	// BmhMatchExpressions - Label selector requirements (In, NotIn, Exists, DoesNotExist) for a further
+	// sub-selection of BaremetalHosts, on top of bmhLabelSelector
+	BmhMatchExpressions []metav1.LabelSelectorRequirement `json:"bmhMatchExpressions,omitempty"`
+	// +kubebuilder:validation:Optional
 	// Hardware requests for sub-selection of BaremetalHosts with certain hardware specs
 	HardwareReqs HardwareReqs `json:"hardwareReqs,omitempty"`
 	// +kubebuilder:validation:Optional
@@ -141,6 +273,10 @@ type OpenStackBaremetalSetTemplateSpec struct {
 	// DomainName is the domain name that will be set on the underlying Metal3 BaremetalHosts (TODO: acquire this is another manner?)
 	// +kubebuilder:validation:Optional
 	DomainName string `json:"domainName,omitempty"`
+	// +kubebuilder:validation:Optional
+	// ProvisioningTimeout - Maximum time a BaremetalHost may take to reach the provisioned state once it
+	// has been allocated to this set (e.g. 1h30m). Hosts exceeding it are reported as failed. No timeout when unset.
+	ProvisioningTimeout *metav1.Duration `json:"provisioningTimeout,omitempty"`
 }
 
 // OpenStackBaremetalSetSpec defines the desired state of OpenStackBaremetalSet
@@ -155,16 +291,189 @@ type OpenStackBaremetalSetSpec struct {
 	// CtlplaneVlan - Vlan for ctlplane network
 	CtlplaneVlan *int `json:"ctlplaneVlan,omitempty"`
 	// +kubebuilder:validation:Optional
+	// CtlplaneRoutes - Static routes through the ctlplane network, e.g. to the ctlplane subnets of the other
+	// leafs. Each goes through the ctlplane address of the IP version of its destination
+	CtlplaneRoutes []Route `json:"ctlplaneRoutes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=68
+	// CtlplaneMTU - MTU of the ctlplane links: its interface or bond, and VLAN. The interface or bond gets
+	// the MTU of the other networks on it instead when bigger
+	CtlplaneMTU int `json:"ctlplaneMtu,omitempty"`
+	// +kubebuilder:validation:Optional
 	// BootstrapDNS - initial DNS nameserver values to set on the BaremetalHosts when they are provisioned.
 	// Note that subsequent deployment will overwrite these values
 	BootstrapDNS []string `json:"bootstrapDns,omitempty"`
 	// +kubebuilder:validation:Optional
 	// DNSSearchDomains - initial DNS nameserver values to set on the BaremetalHosts when they are provisioned.
 	// Note that subsequent deployment will overwrite these values
-	DNSSearchDomains                  []string `json:"dnsSearchDomains,omitempty"`
+	DNSSearchDomains []string `json:"dnsSearchDomains,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	// Networks - Networks besides ctlplane to configure on the provisioned nodes, e.g. internalapi, storage
+	// and tenant. The ip of each host is given in the networks of baremetalHosts, except for hosts bringing
+	// their own networkData, which configure their networks themselves
+	Networks []NetworkSpec `json:"networks,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Remediation - Policy for automatically replacing BaremetalHosts that failed to provision
+	Remediation *RemediationPolicy `json:"remediation,omitempty"`
+	// +kubebuilder:validation:Optional
+	// ReimageStrategy - How already provisioned hosts are re-provisioned with a new OS image. Provisioned
+	// hosts are never re-imaged when unset
+	ReimageStrategy *ReimageStrategy `json:"reimageStrategy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Immediate;RequireAnnotation
+	// +kubebuilder:default=Immediate
+	// ScaleDownPolicy - Immediate deprovisions hosts as soon as they are removed from baremetalHosts.
+	// RequireAnnotation only allows removing hosts whose BaremetalHost is annotated with
+	// baremetal.openstack.org/delete-host
+	ScaleDownPolicy ScaleDownPolicy `json:"scaleDownPolicy"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +kubebuilder:default=Delete
+	// DeletionPolicy - Delete deprovisions the BaremetalHosts when the OpenStackBaremetalSet is deleted.
+	// Retain only releases them, leaving the nodes running with their image, userData and networkData
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=false
+	// Paused - Stop handing further hosts to Metal3. Hosts already being provisioned carry on
+	Paused bool `json:"paused"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// MaxConcurrentProvisioning - Maximum number of hosts being provisioned at once, further hosts wait
+	// for earlier ones to finish. No limit when unset or 0
+	MaxConcurrentProvisioning int `json:"maxConcurrentProvisioning,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=topologyKey
+	// TopologySpreadConstraints - Spread the hosts of the set evenly across failure domains, such as racks or
+	// zones, identified by the value of a BaremetalHost label. New hosts are only allocated BaremetalHosts
+	// carrying every topologyKey
+	TopologySpreadConstraints []TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// +kubebuilder:validation:Optional
+	// RootDeviceHints - Hints for the disk the OS gets installed on, set on the BaremetalHosts of the hosts
+	// without rootDeviceHints of their own
+	RootDeviceHints *metal3v1.RootDeviceHints `json:"rootDeviceHints,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=WWN;SerialNumber
+	// RootDeviceHintsFrom - For hosts without rootDeviceHints, from either the host or the set, derive them
+	// from the first disk of the BaremetalHost satisfying the diskReqs of the host, NVMe disks first if
+	// nvmeReq prefers them. WWN hints the disk by its WWN, or by its serial number if it has none.
+	// SerialNumber always hints it by its serial number
+	RootDeviceHintsFrom RootDeviceHintsSource `json:"rootDeviceHintsFrom,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Name;PXE
+	// InterfaceMACAddressesFrom - Give the interfaces of the generated networkData the MAC address of a NIC
+	// from the hardware details of the BaremetalHost, so they are found whatever the OS names them. Name
+	// resolves every interface by its name as inspected. PXE does too, but resolves the ctlplaneInterface,
+	// unless it is a bond, to the NIC the BaremetalHost PXE boots from. Interfaces are only named when unset
+	InterfaceMACAddressesFrom         InterfaceMACAddressSource `json:"interfaceMacAddressesFrom,omitempty"`
 	OpenStackBaremetalSetTemplateSpec `json:",inline"`
 }
 
+// TopologySpreadConstraint defines how evenly the hosts of a set are spread across the values of a
+// BaremetalHost label
+type TopologySpreadConstraint struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	// TopologyKey - BaremetalHost label whose values are the failure domains, e.g. topology.kubernetes.io/zone
+	TopologyKey string `json:"topologyKey"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// MaxSkew - Maximum difference between the number of hosts in the most and least populated domains.
+	// Every domain of the free or allocated BaremetalHosts counts, including those without any host yet
+	// A set already spread beyond it, e.g. after a scale-down, can still scale up without growing its skew
+	MaxSkew int `json:"maxSkew"`
+}
+
+// InterfaceMACAddressSource - which NIC of a BaremetalHost gives an interface of the networkData its MAC address
+type InterfaceMACAddressSource string
+
+const (
+	// InterfaceMACAddressesFromName - the NIC of the same name
+	InterfaceMACAddressesFromName InterfaceMACAddressSource = "Name"
+	// InterfaceMACAddressesFromPXE - the PXE NIC for the ctlplane interface, the NIC of the same name otherwise
+	InterfaceMACAddressesFromPXE InterfaceMACAddressSource = "PXE"
+)
+
+// RootDeviceHintsSource - which identifier of a disk derived rootDeviceHints use
+type RootDeviceHintsSource string
+
+const (
+	// RootDeviceHintsFromWWN - hint the disk by its WWN, falling back to its serial number
+	RootDeviceHintsFromWWN RootDeviceHintsSource = "WWN"
+	// RootDeviceHintsFromSerialNumber - hint the disk by its serial number
+	RootDeviceHintsFromSerialNumber RootDeviceHintsSource = "SerialNumber"
+)
+
+// ScaleDownPolicy - when hosts removed from an OpenStackBaremetalSet get deprovisioned
+type ScaleDownPolicy string
+
+const (
+	// ScaleDownPolicyImmediate - deprovision hosts as soon as they are removed from baremetalHosts
+	ScaleDownPolicyImmediate ScaleDownPolicy = "Immediate"
+	// ScaleDownPolicyRequireAnnotation - only deprovision removed hosts whose BaremetalHost is annotated for deletion
+	ScaleDownPolicyRequireAnnotation ScaleDownPolicy = "RequireAnnotation"
+)
+
+// DeletionPolicy - what happens to the BaremetalHosts of a deleted OpenStackBaremetalSet
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete - deprovision the BaremetalHosts
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain - release the BaremetalHosts without deprovisioning them
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
+// ReimageStrategyType - which provisioned hosts get re-imaged
+type ReimageStrategyType string
+
+const (
+	// ReimageStrategyRolling - re-image every host whose BaremetalHost runs an outdated OS image, as well
+	// as those annotated for re-image
+	ReimageStrategyRolling ReimageStrategyType = "Rolling"
+	// ReimageStrategyOnAnnotation - only re-image hosts whose BaremetalHost is annotated for re-image
+	ReimageStrategyOnAnnotation ReimageStrategyType = "OnAnnotation"
+)
+
+// ReimageStrategy defines how provisioned hosts are re-provisioned, keeping their hostname, IPs and
+// cloud-init data
+type ReimageStrategy struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Rolling;OnAnnotation
+	// +kubebuilder:default=Rolling
+	// Type - Rolling re-images hosts running an outdated OS image and hosts annotated for re-image,
+	// OnAnnotation only the annotated ones
+	Type ReimageStrategyType `json:"type"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// MaxUnavailable - Maximum number of hosts that may be unprovisioned at once, including hosts
+	// still provisioning or being re-imaged
+	MaxUnavailable int `json:"maxUnavailable"`
+}
+
+// RemediationPolicy defines how BaremetalHosts that failed to provision are replaced
+type RemediationPolicy struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=false
+	// ReplaceFailedHosts - Release a BaremetalHost that failed to provision and allocate another matching
+	// BaremetalHost from the free pool to the same hostname, keeping its control plane IP. The released
+	// BaremetalHost is annotated so that the set never allocates it again. Hosts pinned with bmhName or
+	// adopted keep their BaremetalHost and are only reported as failed
+	ReplaceFailedHosts bool `json:"replaceFailedHosts"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=false
+	// Quarantine - Label the released BaremetalHost as quarantined so that it is not selected again by any
+	// OpenStackBaremetalSet until the label is removed
+	Quarantine bool `json:"quarantine"`
+	// +kubebuilder:validation:Optional
+	// QuarantineTaints - Taints added to the released BaremetalHost
+	QuarantineTaints []corev1.Taint `json:"quarantineTaints,omitempty"`
+}
+
 // OpenStackBaremetalSetStatus defines the observed state of OpenStackBaremetalSet
 type OpenStackBaremetalSetStatus struct {
 	// Conditions
@@ -178,6 +487,57 @@ type OpenStackBaremetalSetStatus struct {
 	// then the controller has not processed the latest changes injected by
 	// the opentack-operator in the top-level CR (e.g. the ContainerImage)
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Capacity - BaremetalHosts the OpenStackBaremetalSet could still scale up onto
+	Capacity *CapacityStatus `json:"capacity,omitempty"`
+}
+
+// CapacityStatus reports the BaremetalHosts matching the bmhLabelSelector of an OpenStackBaremetalSet
+// that are free to be allocated to new hosts, and why the remaining ones are not
+type CapacityStatus struct {
+	// FreeBmhs - Number of BaremetalHosts that can be allocated to new hosts
+	FreeBmhs int `json:"freeBmhs"`
+
+	// +kubebuilder:validation:Optional
+	// Candidates - Names of the BaremetalHosts that can be allocated to new hosts
+	Candidates []string `json:"candidates,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Rejected - BaremetalHosts that cannot be allocated to new hosts
+	Rejected []BmhRejection `json:"rejected,omitempty"`
+}
+
+// BmhRejectionReason - why a BaremetalHost cannot be allocated to a new host
+type BmhRejectionReason string
+
+const (
+	// BmhRejectionNotAvailable - the BMH provisioning state is not 'available'
+	BmhRejectionNotAvailable BmhRejectionReason = "NotAvailable"
+	// BmhRejectionHardwareMismatch - the BMH does not satisfy the hardwareReqs
+	BmhRejectionHardwareMismatch BmhRejectionReason = "HardwareMismatch"
+	// BmhRejectionConsumed - the BMH already has a consumerRef
+	BmhRejectionConsumed BmhRejectionReason = "Consumed"
+	// BmhRejectionHasImage - the BMH already has an image set
+	BmhRejectionHasImage BmhRejectionReason = "HasImage"
+	// BmhRejectionExternallyProvisioned - the BMH is externally provisioned
+	BmhRejectionExternallyProvisioned BmhRejectionReason = "ExternallyProvisioned"
+	// BmhRejectionHasCustomDeploy - the BMH already has a customDeploy set
+	BmhRejectionHasCustomDeploy BmhRejectionReason = "HasCustomDeploy"
+	// BmhRejectionQuarantined - the BMH carries the quarantine label
+	BmhRejectionQuarantined BmhRejectionReason = "Quarantined"
+	// BmhRejectionFailed - the BMH already failed to provision for the set, as per its FailedBmhAnnotation
+	BmhRejectionFailed BmhRejectionReason = "Failed"
+	// BmhRejectionMissingTopologyLabel - the BMH lacks the topologyKey label of a topologySpreadConstraint
+	BmhRejectionMissingTopologyLabel BmhRejectionReason = "MissingTopologyLabel"
+)
+
+// BmhRejection lists why a BaremetalHost cannot be allocated to a new host
+type BmhRejection struct {
+	// Name - Name of the BaremetalHost
+	Name string `json:"name"`
+
+	// Reasons - Every reason that rules the BaremetalHost out
+	Reasons []BmhRejectionReason `json:"reasons"`
 }
 
 // +kubebuilder:object:root=true
@@ -211,6 +571,20 @@ type OpenStackBaremetalSetList struct {
 // ProvisioningState - the overall state of a BMH
 type ProvisioningState string
 
+// ProvisioningStateFailed - set by the operator, in place of the Metal3 state, on hosts that reported
+// a Metal3 provisioning error or exceeded the set's provisioning timeout
+const ProvisioningStateFailed ProvisioningState = "failed"
+
+// ReimagePhase - the progress of a host being re-imaged
+type ReimagePhase string
+
+const (
+	// ReimagePhaseDeprovisioning - the image was removed from the BMH, waiting for Metal3 to deprovision it
+	ReimagePhaseDeprovisioning ReimagePhase = "Deprovisioning"
+	// ReimagePhaseProvisioning - the BMH was deprovisioned and is being provisioned with the current image
+	ReimagePhaseProvisioning ReimagePhase = "Provisioning"
+)
+
 // IPStatus represents the hostname and IP info for a specific host
 type IPStatus struct {
 	Hostname string `json:"hostname"`
@@ -222,6 +596,19 @@ type IPStatus struct {
 	IPAddresses map[string]string `json:"ipAddresses"`
 }
 
+// ProvisioningPhase records a provisioning state a BMH went through and when
+type ProvisioningPhase struct {
+	// State - Metal3 provisioning state of the BMH during this phase
+	State ProvisioningState `json:"state"`
+
+	// StartTime - When the BMH was first observed in this state
+	StartTime metav1.Time `json:"startTime"`
+
+	// +kubebuilder:validation:Optional
+	// EndTime - When the BMH was first observed to have left this state, unset for the current phase
+	EndTime *metav1.Time `json:"endTime,omitempty"`
+}
+
 // HostStatus represents the IPStatus and provisioning state + deployment information
 type HostStatus struct {
 
@@ -230,8 +617,36 @@ type HostStatus struct {
 
 	ProvisioningState ProvisioningState `json:"provisioningState"`
 
+	// +kubebuilder:validation:Optional
+	// ProvisioningStartTime - When the BMH was allocated to this host
+	ProvisioningStartTime *metav1.Time `json:"provisioningStartTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ProvisioningHistory - Most recent provisioning state transitions of the BMH, oldest first
+	ProvisioningHistory []ProvisioningPhase `json:"provisioningHistory,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// OperationalStatus - Operational status reported by Metal3 for the BMH
+	OperationalStatus string `json:"operationalStatus,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ErrorType - Type of the last error reported by Metal3 for the BMH, if any
+	ErrorType string `json:"errorType,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ErrorMessage - Last error message reported by Metal3 for the BMH, if any
+	ErrorMessage string `json:"errorMessage,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Adopted - The BMH was adopted already provisioned, rather than provisioned by this set
+	Adopted bool `json:"adopted,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ReimagePhase - Progress of the re-image of the host, unset when it is not being re-imaged
+	ReimagePhase ReimagePhase `json:"reimagePhase,omitempty"`
+
 	// +kubebuilder:default=false
-	// Host annotated for deletion
+	// Host annotated for deletion with baremetal.openstack.org/delete-host
 	AnnotatedForDeletion bool `json:"annotatedForDeletion"`
 
 	UserDataSecretName    string `json:"userDataSecretName"`
@@ -240,16 +655,19 @@ type HostStatus struct {
 
 // HardwareReqs defines request hardware attributes for the BaremetalHost replicas
 type HardwareReqs struct {
-	CPUReqs  CPUReqs  `json:"cpuReqs,omitempty"`
-	MemReqs  MemReqs  `json:"memReqs,omitempty"`
-	DiskReqs DiskReqs `json:"diskReqs,omitempty"`
+	CPUReqs      CPUReqs      `json:"cpuReqs,omitempty"`
+	MemReqs      MemReqs      `json:"memReqs,omitempty"`
+	DiskReqs     DiskReqs     `json:"diskReqs,omitempty"`
+	NICReqs      NICReqs      `json:"nicReqs,omitempty"`
+	SystemReqs   SystemReqs   `json:"systemReqs,omitempty"`
+	FirmwareReqs FirmwareReqs `json:"firmwareReqs,omitempty"`
 }
 
 // CPUReqs defines specific CPU hardware requests
 type CPUReqs struct {
 	// Arch is a scalar (string) because it wouldn't make sense to give it an "exact-match" option
-	// Can be either "x86_64" or "ppc64le" if included
-	// +kubebuilder:validation:Enum=x86_64;ppc64le
+	// Can be either "x86_64", "ppc64le" or "aarch64" if included
+	// +kubebuilder:validation:Enum=x86_64;ppc64le;aarch64
 	Arch     string      `json:"arch,omitempty"`
 	CountReq CPUCountReq `json:"countReq,omitempty"`
 	MhzReq   CPUMhzReq   `json:"mhzReq,omitempty"`
@@ -284,11 +702,17 @@ type MemGbReq struct {
 	ExactMatch bool `json:"exactMatch,omitempty"`
 }
 
-// DiskReqs defines specific disk hardware requests
+// DiskReqs defines specific disk hardware requests. Every request applies to the same disk(s), so that
+// e.g. GbReq and SSDReq together ask for an SSD of that size
 type DiskReqs struct {
 	GbReq DiskGbReq `json:"gbReq,omitempty"`
 	// SSD is scalar (bool) because it wouldn't make sense to give it an "exact-match" option
 	SSDReq DiskSSDReq `json:"ssdReq,omitempty"`
+	// NVMe is scalar (bool) for the same reason. Unlike the other disk requests it is a preference:
+	// BaremetalHosts with enough NVMe disks satisfying the other disk requests are allocated first
+	NVMeReq DiskNVMeReq `json:"nvmeReq,omitempty"`
+	// CountReq is the number of disks satisfying the other disk requests, one if not set
+	CountReq DiskCountReq `json:"countReq,omitempty"`
 }
 
 // DiskGbReq defines a specific hardware request for disk size
@@ -308,6 +732,56 @@ type DiskSSDReq struct {
 	ExactMatch bool `json:"exactMatch,omitempty"`
 }
 
+// DiskNVMeReq defines a specific hardware preference for disk of type NVMe
+type DiskNVMeReq struct {
+	NVMe bool `json:"nvme,omitempty"`
+}
+
+// DiskCountReq defines a specific hardware request for the number of matching disks
+type DiskCountReq struct {
+	// +kubebuilder:validation:Minimum=1
+	Count int `json:"count,omitempty"`
+	// If ExactMatch == false, actual count > Count will match
+	ExactMatch bool `json:"exactMatch,omitempty"`
+}
+
+// NICReqs defines specific network interface hardware requests
+type NICReqs struct {
+	// CountReq is the number of NICs satisfying SpeedReq, or of all NICs if SpeedReq is not set
+	CountReq NICCountReq `json:"countReq,omitempty"`
+	SpeedReq NICSpeedReq `json:"speedReq,omitempty"`
+}
+
+// NICCountReq defines a specific hardware request for the number of matching NICs
+type NICCountReq struct {
+	// +kubebuilder:validation:Minimum=1
+	Count int `json:"count,omitempty"`
+	// If ExactMatch == false, actual count > Count will match
+	ExactMatch bool `json:"exactMatch,omitempty"`
+}
+
+// NICSpeedReq defines a specific hardware request for NIC speed
+type NICSpeedReq struct {
+	// +kubebuilder:validation:Minimum=1
+	Gbps int `json:"gbps,omitempty"`
+	// If ExactMatch == false, actual Gbps > Gbps will match
+	ExactMatch bool `json:"exactMatch,omitempty"`
+}
+
+// SystemReqs defines specific requests for the system vendor, as reported by the BaremetalHost inspection.
+// Both are exact-match only
+type SystemReqs struct {
+	Manufacturer string `json:"manufacturer,omitempty"`
+	ProductName  string `json:"productName,omitempty"`
+}
+
+// FirmwareReqs defines specific firmware requests
+type FirmwareReqs struct {
+	// MinBIOSVersion is the lowest BIOS version that will match. Versions are compared
+	// numerically on their digit runs and lexically on the rest, so "2.10.1" > "2.9"
+	MinBIOSVersion string `json:"minBiosVersion,omitempty"`
+}
+
 //
 // BEGIN - functions
 // NOTE: Eventually we will need to move certain functions from the main module's "pkg" dir
@@ -24,6 +24,9 @@ package v1beta1
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
@@ -74,24 +77,136 @@ func (r *OpenStackBaremetalSet) ValidateCreate() (admission.Warnings, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if err := r.ValidateBmhSelectors(); err != nil {
+		return nil, err
+	}
+
+	if err := r.Spec.ValidateArchImages(); err != nil {
+		return nil, err
+	}
+
+	if err := VerifyBaremetalSetNetworks(r); err != nil {
+		return nil, err
+	}
+
+	if err := r.ValidateAdoption(); err != nil {
+		return nil, err
+	}
+
+	if err := r.ValidateBmhPinning(); err != nil {
+		return nil, err
+	}
 	//
 	// Validate that there are enough available BMHs for the initial requested count
 	//
-	baremetalHostsList, err := GetBaremetalHosts(
+	baremetalHostsList, err := GetBaremetalSetBaremetalHosts(context.TODO(), webhookClient, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := VerifyBaremetalSetScaleUp(openstackbaremetalsetlog, BinPackingBmhScorer{}, r, baremetalHostsList, &metal3v1.BareMetalHostList{}); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// ValidateBmhSelectors checks that the bmhMatchExpressions of the set and of its hosts are valid
+func (r *OpenStackBaremetalSet) ValidateBmhSelectors() error {
+	if _, err := r.Spec.BmhSelector(); err != nil {
+		return err
+	}
+	for hostName, compute := range r.Spec.BaremetalHosts {
+		if _, err := compute.BmhSelector(); err != nil {
+			return fmt.Errorf("host %s: %w", hostName, err)
+		}
+	}
+	return nil
+}
+
+// ValidateArchImages checks that archImages are given for supported CPU architectures, with an image to use
+func (spec OpenStackBaremetalSetTemplateSpec) ValidateArchImages() error {
+	for _, arch := range slices.Sorted(maps.Keys(spec.ArchImages)) {
+		archImage := spec.ArchImages[arch]
+		if !slices.Contains(SupportedCPUArchs, arch) {
+			return fmt.Errorf("archImages: unsupported CPU architecture %q, must be one of %s",
+				arch, strings.Join(SupportedCPUArchs, ", "))
+		}
+		if spec.OSImageDeploymentType == OSImageDeploymentTypePassThrough {
+			if archImage.OSContainerImageURL == "" {
+				return fmt.Errorf("archImages: %s requires osContainerImageUrl in PassThrough mode", arch)
+			}
+		} else if archImage.OSContainerImageURL == "" && archImage.OSImage == "" {
+			return fmt.Errorf("archImages: %s requires osImage or osContainerImageUrl", arch)
+		}
+	}
+	return nil
+}
+
+// ValidateAdoption checks that the BaremetalHosts to be adopted exist, can be adopted and are not used by
+// another host of this or another OpenStackBaremetalSet
+func (r *OpenStackBaremetalSet) ValidateAdoption() error {
+	adopting := false
+	for _, compute := range r.Spec.BaremetalHosts {
+		if compute.AdoptBmh != "" {
+			adopting = true
+			break
+		}
+	}
+	if !adopting {
+		return nil
+	}
+
+	allBaremetalHosts, err := GetBaremetalHosts(
 		context.TODO(),
 		webhookClient,
 		r.Spec.BmhNamespace,
-		r.Spec.BmhLabelSelector,
+		map[string]string{},
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if _, err := VerifyBaremetalSetScaleUp(openstackbaremetalsetlog, r, baremetalHostsList, &metal3v1.BareMetalHostList{}); err != nil {
-		return nil, err
+	baremetalSets := &OpenStackBaremetalSetList{}
+	if err := webhookClient.List(context.TODO(), baremetalSets); err != nil {
+		return err
 	}
 
-	return nil, nil
+	_, err = VerifyBaremetalSetAdoption(r, allBaremetalHosts, baremetalSets.Items)
+	return err
+}
+
+// ValidateBmhPinning checks that the BaremetalHosts named by bmhName exist, are free and are not pinned
+// by another OpenStackBaremetalSet
+func (r *OpenStackBaremetalSet) ValidateBmhPinning() error {
+	pinning := false
+	for _, compute := range r.Spec.BaremetalHosts {
+		if compute.BmhName != "" {
+			pinning = true
+			break
+		}
+	}
+	if !pinning {
+		return nil
+	}
+
+	allBaremetalHosts, err := GetBaremetalHosts(
+		context.TODO(),
+		webhookClient,
+		r.Spec.BmhNamespace,
+		map[string]string{},
+	)
+	if err != nil {
+		return err
+	}
+
+	baremetalSets := &OpenStackBaremetalSetList{}
+	if err := webhookClient.List(context.TODO(), baremetalSets); err != nil {
+		return err
+	}
+
+	return VerifyBaremetalSetPinning(r, allBaremetalHosts, baremetalSets.Items)
 }
 
 // ValidateSecretNamespaces checks if secret references are in their expected namespaces
@@ -124,8 +239,9 @@ func (r *OpenStackBaremetalSet) ValidateSecretNamespaces() error {
 func (spec OpenStackBaremetalSetTemplateSpec) ValidateTemplate(oldCount int, oldSpec OpenStackBaremetalSetTemplateSpec) error {
 	if oldCount > 0 &&
 		(!equality.Semantic.DeepEqual(spec.BmhLabelSelector, oldSpec.BmhLabelSelector) ||
+			!equality.Semantic.DeepEqual(spec.BmhMatchExpressions, oldSpec.BmhMatchExpressions) ||
 			!equality.Semantic.DeepEqual(spec.HardwareReqs, oldSpec.HardwareReqs)) {
-		return fmt.Errorf("cannot change \"bmhLabelSelector\" nor \"hardwareReqs\" when previous count of \"baremetalHosts\" > 0")
+		return fmt.Errorf("cannot change \"bmhLabelSelector\", \"bmhMatchExpressions\" nor \"hardwareReqs\" when previous count of \"baremetalHosts\" > 0")
 	}
 	return nil
 }
@@ -150,8 +266,28 @@ func (r *OpenStackBaremetalSet) ValidateUpdate(old runtime.Object) (admission.Wa
 		return nil, err
 	}
 
+	if err := r.ValidateBmhSelectors(); err != nil {
+		return nil, err
+	}
+
+	if err := r.Spec.ValidateArchImages(); err != nil {
+		return nil, err
+	}
+
+	if err := VerifyBaremetalSetNetworks(r); err != nil {
+		return nil, err
+	}
+
+	if err := r.ValidateAdoption(); err != nil {
+		return nil, err
+	}
+
+	if err := r.ValidateBmhPinning(); err != nil {
+		return nil, err
+	}
+
 	//
-	// Force BmhLabelSelector and HardwareReqs to remain the same unless the *old* count of spec.BaremetalHosts was 0.
+	// Force BmhLabelSelector, BmhMatchExpressions and HardwareReqs to remain the same unless the *old* count of spec.BaremetalHosts was 0.
 	// We do this to maintain consistency across the gathered list of BMHs during reconcile.
 	//
 	oldCount := len(oldInstance.Spec.BaremetalHosts)
@@ -174,12 +310,7 @@ func (r *OpenStackBaremetalSet) ValidateUpdate(old runtime.Object) (admission.Wa
 		//
 		if newCount > oldCount {
 			// Every BMH available that matches our (optional) labels
-			baremetalHostsList, err := GetBaremetalHosts(
-				context.TODO(),
-				webhookClient,
-				r.Spec.BmhNamespace,
-				r.Spec.BmhLabelSelector,
-			)
+			baremetalHostsList, err := GetBaremetalSetBaremetalHosts(context.TODO(), webhookClient, r)
 			if err != nil {
 				return nil, err
 			}
@@ -195,12 +326,32 @@ func (r *OpenStackBaremetalSet) ValidateUpdate(old runtime.Object) (admission.Wa
 				return nil, err
 			}
 
-			if _, err := VerifyBaremetalSetScaleUp(openstackbaremetalsetlog, r, baremetalHostsList, existingBaremetalHosts); err != nil {
+			if _, err := VerifyBaremetalSetScaleUp(openstackbaremetalsetlog, BinPackingBmhScorer{}, r, baremetalHostsList, existingBaremetalHosts); err != nil {
 				return nil, err
 			}
 		}
 	}
 
+	//
+	// Hosts can also be removed (or renamed) without changing the count, so always
+	// check scale-down against the scale-down policy
+	//
+	if r.Spec.ScaleDownPolicy == ScaleDownPolicyRequireAnnotation {
+		existingBaremetalHosts, err := GetBaremetalHosts(
+			context.TODO(),
+			webhookClient,
+			r.Spec.BmhNamespace,
+			labels.GetLabels(r, labels.GetGroupLabel(ServiceName), map[string]string{}),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := VerifyBaremetalSetScaleDown(r, existingBaremetalHosts); err != nil {
+			return nil, err
+		}
+	}
+
 	return nil, nil
 }
 
@@ -0,0 +1,43 @@
+package v1beta1
+
+import (
+	. "github.com/onsi/ginkgo/v2" //revive:disable:dot-imports
+	. "github.com/onsi/gomega"    //revive:disable:dot-imports
+)
+
+var _ = Describe("ValidateArchImages", func() {
+	var spec OpenStackBaremetalSetTemplateSpec
+
+	BeforeEach(func() {
+		spec = OpenStackBaremetalSetTemplateSpec{
+			OSImageDeploymentType: OSImageDeploymentTypeSelfExtracting,
+			ArchImages: map[string]ArchImage{
+				"aarch64": {OSImage: "edpm-hardened-uefi-aarch64.qcow2"},
+			},
+		}
+	})
+
+	It("accepts images for the supported CPU archs", func() {
+		spec.ArchImages["ppc64le"] = ArchImage{OSContainerImageURL: "quay.io/edpm-hardened-uefi-ppc64le"}
+		Expect(spec.ValidateArchImages()).To(Succeed())
+	})
+
+	It("rejects unsupported CPU archs", func() {
+		spec.ArchImages["riscv64"] = ArchImage{OSContainerImageURL: "quay.io/edpm-hardened-uefi-riscv64"}
+		err := spec.ValidateArchImages()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unsupported CPU architecture \"riscv64\""))
+	})
+
+	It("requires an image for every CPU arch", func() {
+		spec.ArchImages["aarch64"] = ArchImage{}
+		Expect(spec.ValidateArchImages()).NotTo(Succeed())
+	})
+
+	It("requires osContainerImageUrl in PassThrough mode", func() {
+		spec.OSImageDeploymentType = OSImageDeploymentTypePassThrough
+		err := spec.ValidateArchImages()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("aarch64 requires osContainerImageUrl in PassThrough mode"))
+	})
+})
@@ -104,6 +104,8 @@ type OpenStackProvisionServerStatus struct {
 	OSImageChecksumFilename string `json:"osImageChecksumFilename,omitempty"`
 	// OSImage checksum type
 	OSImageChecksumType metal3v1.ChecksumType `json:"osImageChecksumType,omitempty"`
+	// Size in bytes of the OS image, as reported by the checksum discovery agent
+	OSImageSize int64 `json:"osImageSize,omitempty"`
 	// URL of provisioning image checksum on underlying Apache web server
 	LocalImageChecksumURL string `json:"localImageChecksumUrl,omitempty"`
 }
@@ -21,11 +21,48 @@ limitations under the License.
 package v1beta1
 
 import (
+	"github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/openstack-k8s-operators/lib-common/modules/common/condition"
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ArchImage) DeepCopyInto(out *ArchImage) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArchImage.
+func (in *ArchImage) DeepCopy() *ArchImage {
+	if in == nil {
+		return nil
+	}
+	out := new(ArchImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BmhRejection) DeepCopyInto(out *BmhRejection) {
+	*out = *in
+	if in.Reasons != nil {
+		in, out := &in.Reasons, &out.Reasons
+		*out = make([]BmhRejectionReason, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BmhRejection.
+func (in *BmhRejection) DeepCopy() *BmhRejection {
+	if in == nil {
+		return nil
+	}
+	out := new(BmhRejection)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BondConfig) DeepCopyInto(out *BondConfig) {
 	*out = *in
@@ -100,6 +137,63 @@ func (in *CPUReqs) DeepCopy() *CPUReqs {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacityStatus) DeepCopyInto(out *CapacityStatus) {
+	*out = *in
+	if in.Candidates != nil {
+		in, out := &in.Candidates, &out.Candidates
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rejected != nil {
+		in, out := &in.Rejected, &out.Rejected
+		*out = make([]BmhRejection, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacityStatus.
+func (in *CapacityStatus) DeepCopy() *CapacityStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacityStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CtlplaneAddress) DeepCopyInto(out *CtlplaneAddress) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CtlplaneAddress.
+func (in *CtlplaneAddress) DeepCopy() *CtlplaneAddress {
+	if in == nil {
+		return nil
+	}
+	out := new(CtlplaneAddress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskCountReq) DeepCopyInto(out *DiskCountReq) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskCountReq.
+func (in *DiskCountReq) DeepCopy() *DiskCountReq {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskCountReq)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DiskGbReq) DeepCopyInto(out *DiskGbReq) {
 	*out = *in
@@ -115,11 +209,28 @@ func (in *DiskGbReq) DeepCopy() *DiskGbReq {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskNVMeReq) DeepCopyInto(out *DiskNVMeReq) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskNVMeReq.
+func (in *DiskNVMeReq) DeepCopy() *DiskNVMeReq {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskNVMeReq)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DiskReqs) DeepCopyInto(out *DiskReqs) {
 	*out = *in
 	out.GbReq = in.GbReq
 	out.SSDReq = in.SSDReq
+	out.NVMeReq = in.NVMeReq
+	out.CountReq = in.CountReq
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskReqs.
@@ -147,12 +258,30 @@ func (in *DiskSSDReq) DeepCopy() *DiskSSDReq {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirmwareReqs) DeepCopyInto(out *FirmwareReqs) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirmwareReqs.
+func (in *FirmwareReqs) DeepCopy() *FirmwareReqs {
+	if in == nil {
+		return nil
+	}
+	out := new(FirmwareReqs)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HardwareReqs) DeepCopyInto(out *HardwareReqs) {
 	*out = *in
 	out.CPUReqs = in.CPUReqs
 	out.MemReqs = in.MemReqs
 	out.DiskReqs = in.DiskReqs
+	out.NICReqs = in.NICReqs
+	out.SystemReqs = in.SystemReqs
+	out.FirmwareReqs = in.FirmwareReqs
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareReqs.
@@ -169,6 +298,17 @@ func (in *HardwareReqs) DeepCopy() *HardwareReqs {
 func (in *HostStatus) DeepCopyInto(out *HostStatus) {
 	*out = *in
 	in.IPStatus.DeepCopyInto(&out.IPStatus)
+	if in.ProvisioningStartTime != nil {
+		in, out := &in.ProvisioningStartTime, &out.ProvisioningStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ProvisioningHistory != nil {
+		in, out := &in.ProvisioningHistory, &out.ProvisioningHistory
+		*out = make([]ProvisioningPhase, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostStatus.
@@ -213,11 +353,40 @@ func (in *InstanceSpec) DeepCopyInto(out *InstanceSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.BmhMatchExpressions != nil {
+		in, out := &in.BmhMatchExpressions, &out.BmhMatchExpressions
+		*out = make([]metav1.LabelSelectorRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CtlPlaneIPs != nil {
+		in, out := &in.CtlPlaneIPs, &out.CtlPlaneIPs
+		*out = make([]CtlplaneAddress, len(*in))
+		copy(*out, *in)
+	}
+	if in.CtlplaneBond != nil {
+		in, out := &in.CtlplaneBond, &out.CtlplaneBond
+		*out = new(BondConfig)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.CtlplaneVlan != nil {
 		in, out := &in.CtlplaneVlan, &out.CtlplaneVlan
 		*out = new(int)
 		**out = **in
 	}
+	if in.CtlplaneRoutes != nil {
+		in, out := &in.CtlplaneRoutes, &out.CtlplaneRoutes
+		*out = make([]Route, len(*in))
+		copy(*out, *in)
+	}
+	if in.Networks != nil {
+		in, out := &in.Networks, &out.Networks
+		*out = make([]NetworkSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.UserData != nil {
 		in, out := &in.UserData, &out.UserData
 		*out = new(v1.SecretReference)
@@ -228,6 +397,16 @@ func (in *InstanceSpec) DeepCopyInto(out *InstanceSpec) {
 		*out = new(v1.SecretReference)
 		**out = **in
 	}
+	if in.HardwareReqs != nil {
+		in, out := &in.HardwareReqs, &out.HardwareReqs
+		*out = new(HardwareReqs)
+		**out = **in
+	}
+	if in.RootDeviceHints != nil {
+		in, out := &in.RootDeviceHints, &out.RootDeviceHints
+		*out = new(v1alpha1.RootDeviceHints)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceSpec.
@@ -271,6 +450,83 @@ func (in *MemReqs) DeepCopy() *MemReqs {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NICCountReq) DeepCopyInto(out *NICCountReq) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NICCountReq.
+func (in *NICCountReq) DeepCopy() *NICCountReq {
+	if in == nil {
+		return nil
+	}
+	out := new(NICCountReq)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NICReqs) DeepCopyInto(out *NICReqs) {
+	*out = *in
+	out.CountReq = in.CountReq
+	out.SpeedReq = in.SpeedReq
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NICReqs.
+func (in *NICReqs) DeepCopy() *NICReqs {
+	if in == nil {
+		return nil
+	}
+	out := new(NICReqs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NICSpeedReq) DeepCopyInto(out *NICSpeedReq) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NICSpeedReq.
+func (in *NICSpeedReq) DeepCopy() *NICSpeedReq {
+	if in == nil {
+		return nil
+	}
+	out := new(NICSpeedReq)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
+	*out = *in
+	if in.Bond != nil {
+		in, out := &in.Bond, &out.Bond
+		*out = new(BondConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Vlan != nil {
+		in, out := &in.Vlan, &out.Vlan
+		*out = new(int)
+		**out = **in
+	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]Route, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
+func (in *NetworkSpec) DeepCopy() *NetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OpenStackBaremetalSet) DeepCopyInto(out *OpenStackBaremetalSet) {
 	*out = *in
@@ -345,6 +601,11 @@ func (in *OpenStackBaremetalSetSpec) DeepCopyInto(out *OpenStackBaremetalSetSpec
 		*out = new(int)
 		**out = **in
 	}
+	if in.CtlplaneRoutes != nil {
+		in, out := &in.CtlplaneRoutes, &out.CtlplaneRoutes
+		*out = make([]Route, len(*in))
+		copy(*out, *in)
+	}
 	if in.BootstrapDNS != nil {
 		in, out := &in.BootstrapDNS, &out.BootstrapDNS
 		*out = make([]string, len(*in))
@@ -355,6 +616,33 @@ func (in *OpenStackBaremetalSetSpec) DeepCopyInto(out *OpenStackBaremetalSetSpec
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Networks != nil {
+		in, out := &in.Networks, &out.Networks
+		*out = make([]NetworkSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Remediation != nil {
+		in, out := &in.Remediation, &out.Remediation
+		*out = new(RemediationPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReimageStrategy != nil {
+		in, out := &in.ReimageStrategy, &out.ReimageStrategy
+		*out = new(ReimageStrategy)
+		**out = **in
+	}
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]TopologySpreadConstraint, len(*in))
+		copy(*out, *in)
+	}
+	if in.RootDeviceHints != nil {
+		in, out := &in.RootDeviceHints, &out.RootDeviceHints
+		*out = new(v1alpha1.RootDeviceHints)
+		(*in).DeepCopyInto(*out)
+	}
 	in.OpenStackBaremetalSetTemplateSpec.DeepCopyInto(&out.OpenStackBaremetalSetTemplateSpec)
 }
 
@@ -392,6 +680,11 @@ func (in *OpenStackBaremetalSetStatus) DeepCopyInto(out *OpenStackBaremetalSetSt
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = new(CapacityStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenStackBaremetalSetStatus.
@@ -407,6 +700,13 @@ func (in *OpenStackBaremetalSetStatus) DeepCopy() *OpenStackBaremetalSetStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OpenStackBaremetalSetTemplateSpec) DeepCopyInto(out *OpenStackBaremetalSetTemplateSpec) {
 	*out = *in
+	if in.ArchImages != nil {
+		in, out := &in.ArchImages, &out.ArchImages
+		*out = make(map[string]ArchImage, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.ProvisonServerNodeSelector != nil {
 		in, out := &in.ProvisonServerNodeSelector, &out.ProvisonServerNodeSelector
 		*out = make(map[string]string, len(*in))
@@ -426,12 +726,24 @@ func (in *OpenStackBaremetalSetTemplateSpec) DeepCopyInto(out *OpenStackBaremeta
 			(*out)[key] = val
 		}
 	}
+	if in.BmhMatchExpressions != nil {
+		in, out := &in.BmhMatchExpressions, &out.BmhMatchExpressions
+		*out = make([]metav1.LabelSelectorRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	out.HardwareReqs = in.HardwareReqs
 	if in.PasswordSecret != nil {
 		in, out := &in.PasswordSecret, &out.PasswordSecret
 		*out = new(v1.SecretReference)
 		**out = **in
 	}
+	if in.ProvisioningTimeout != nil {
+		in, out := &in.ProvisioningTimeout, &out.ProvisioningTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenStackBaremetalSetTemplateSpec.
@@ -574,3 +886,105 @@ func (in *OpenStackProvisionServerStatus) DeepCopy() *OpenStackProvisionServerSt
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningPhase) DeepCopyInto(out *ProvisioningPhase) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.EndTime != nil {
+		in, out := &in.EndTime, &out.EndTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningPhase.
+func (in *ProvisioningPhase) DeepCopy() *ProvisioningPhase {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningPhase)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReimageStrategy) DeepCopyInto(out *ReimageStrategy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReimageStrategy.
+func (in *ReimageStrategy) DeepCopy() *ReimageStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(ReimageStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemediationPolicy) DeepCopyInto(out *RemediationPolicy) {
+	*out = *in
+	if in.QuarantineTaints != nil {
+		in, out := &in.QuarantineTaints, &out.QuarantineTaints
+		*out = make([]v1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemediationPolicy.
+func (in *RemediationPolicy) DeepCopy() *RemediationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RemediationPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Route) DeepCopyInto(out *Route) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route.
+func (in *Route) DeepCopy() *Route {
+	if in == nil {
+		return nil
+	}
+	out := new(Route)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SystemReqs) DeepCopyInto(out *SystemReqs) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SystemReqs.
+func (in *SystemReqs) DeepCopy() *SystemReqs {
+	if in == nil {
+		return nil
+	}
+	out := new(SystemReqs)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TopologySpreadConstraint) DeepCopyInto(out *TopologySpreadConstraint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TopologySpreadConstraint.
+func (in *TopologySpreadConstraint) DeepCopy() *TopologySpreadConstraint {
+	if in == nil {
+		return nil
+	}
+	out := new(TopologySpreadConstraint)
+	in.DeepCopyInto(out)
+	return out
+}
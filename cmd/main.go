@@ -243,9 +243,10 @@ func main() {
 		os.Exit(1)
 	}
 	if err := (&controller.OpenStackBaremetalSetReconciler{
-		Client:  mgr.GetClient(),
-		Scheme:  mgr.GetScheme(),
-		Kclient: kclient,
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Kclient:  kclient,
+		Recorder: mgr.GetEventRecorderFor("openstackbaremetalset-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "OpenStackBaremetalSet")
 		os.Exit(1)
@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/spf13/cobra"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -132,6 +134,26 @@ func runChecksumStartCmd(_ *cobra.Command, _ []string) {
 		panic(fmt.Errorf("%w in %s", ErrOSImageNotFound, checksumStartOpts.osImageDir))
 	}
 
+	// The OS image is served under the osImage name of the provision server, or else under the name of its
+	// checksum file without the checksum extension
+	provServer, err := provServerClient.Namespace(checksumStartOpts.provServerNamespace).Get(context.Background(), checksumStartOpts.provServerName, metav1.GetOptions{})
+	if k8s_errors.IsNotFound(err) {
+		// Deleted somehow, so there is nothing to update
+		glog.V(0).Info("Shutting down ChecksumDiscoveryAgent")
+		return
+	}
+	if err != nil {
+		panic(err.Error())
+	}
+	osImage, _, err := unstructured.NestedString(provServer.Object, "spec", "osImage")
+	if err != nil {
+		panic(err.Error())
+	}
+	osImageSize, err := osImageFileSize(checksumStartOpts.osImageDir, osImage, checksumFileName)
+	if err != nil {
+		panic(err.Error())
+	}
+
 	// Try to update status with checksum data until it succeeds, as it's possible to hit "object has been modified" k8s error here
 	for {
 		unstructured, err := provServerClient.Namespace(checksumStartOpts.provServerNamespace).Get(context.Background(), checksumStartOpts.provServerName, metav1.GetOptions{}, "/status")
@@ -153,6 +175,7 @@ func runChecksumStartCmd(_ *cobra.Command, _ []string) {
 
 		status["osImageChecksumFilename"] = checksumFileName
 		status["osImageChecksumType"] = checksumType
+		status["osImageSize"] = osImageSize
 
 		unstructured.Object["status"] = status
 
@@ -161,7 +184,7 @@ func runChecksumStartCmd(_ *cobra.Command, _ []string) {
 		if err != nil {
 			glog.V(0).Infof("Error updating OpenStackProvisionServer %s (namespace %s) \"osImageChecksumFilename\" and \"osImageChecksumType\" status: %s\n", checksumStartOpts.provServerName, checksumStartOpts.provServerNamespace, err)
 		} else {
-			glog.V(0).Infof("Updated OpenStackProvisionServer %s (namespace %s) with status \"osImageChecksumFilename\": %s and \"osImageChecksumType\": %s\n", checksumStartOpts.provServerName, checksumStartOpts.provServerNamespace, checksumFileName, checksumType)
+			glog.V(0).Infof("Updated OpenStackProvisionServer %s (namespace %s) with status \"osImageChecksumFilename\": %s, \"osImageChecksumType\": %s and \"osImageSize\": %d\n", checksumStartOpts.provServerName, checksumStartOpts.provServerNamespace, checksumFileName, checksumType, osImageSize)
 			break
 		}
 
@@ -170,3 +193,15 @@ func runChecksumStartCmd(_ *cobra.Command, _ []string) {
 
 	glog.V(0).Info("Shutting down ChecksumDiscoveryAgent")
 }
+
+// osImageFileSize - Size of the OS image file in dir, named osImage or else after its checksum file
+func osImageFileSize(dir string, osImage string, checksumFileName string) (int64, error) {
+	if osImage == "" {
+		osImage = strings.TrimSuffix(checksumFileName, filepath.Ext(checksumFileName))
+	}
+	info, err := os.Stat(filepath.Join(dir, osImage))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	baremetalv1 "github.com/openstack-k8s-operators/openstack-baremetal-operator/api/v1beta1"
+)
+
+const (
+	metricsNamespace = "openstack_baremetal"
+
+	// BMH status label values of the matching BMHs gauge
+	bmhMetricStatusAvailable = "available"
+	bmhMetricStatusConsumed  = "consumed"
+)
+
+var (
+	baremetalSetHosts = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "baremetalset",
+			Name:      "hosts",
+			Help:      "Number of hosts of an OpenStackBaremetalSet per provisioning state",
+		},
+		[]string{"namespace", "name", "state"},
+	)
+
+	baremetalSetHostProvisioningDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "baremetalset",
+			Name:      "host_provisioning_duration_seconds",
+			Help:      "Time from a BaremetalHost being allocated to a host of an OpenStackBaremetalSet until it is provisioned",
+			// 1 minute up to ~8.5 hours
+			Buckets: prometheus.ExponentialBuckets(60, 2, 10),
+		},
+		[]string{"namespace", "name"},
+	)
+
+	baremetalSetMatchingBmhs = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "baremetalset",
+			Name:      "matching_bmhs",
+			Help:      "Number of BaremetalHosts matching the bmhLabelSelector of an OpenStackBaremetalSet that are available or consumed",
+		},
+		[]string{"namespace", "name", "status"},
+	)
+
+	provisionServerReady = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "provisionserver",
+			Name:      "ready",
+			Help:      "Whether an OpenStackProvisionServer is ready (1) or not (0)",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	provisionServerChecksumDiscoveryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "provisionserver",
+			Name:      "checksum_discovery_duration_seconds",
+			Help:      "Duration of the OS image checksum discovery job of an OpenStackProvisionServer",
+			// 5 seconds up to ~40 minutes
+			Buckets: prometheus.ExponentialBuckets(5, 2, 10),
+		},
+		[]string{"namespace", "name"},
+	)
+
+	provisionServerImageSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "provisionserver",
+			Name:      "image_size_bytes",
+			Help:      "Size of the OS image served by an OpenStackProvisionServer",
+		},
+		[]string{"namespace", "name", "image"},
+	)
+
+	baremetalSetMetricsOnce    sync.Once
+	provisionServerMetricsOnce sync.Once
+)
+
+// registerBaremetalSetMetrics - Register the OpenStackBaremetalSet metrics with the controller-runtime registry
+func registerBaremetalSetMetrics() {
+	baremetalSetMetricsOnce.Do(func() {
+		metrics.Registry.MustRegister(
+			baremetalSetHosts,
+			baremetalSetHostProvisioningDuration,
+			baremetalSetMatchingBmhs,
+		)
+	})
+}
+
+// registerProvisionServerMetrics - Register the OpenStackProvisionServer metrics with the controller-runtime registry
+func registerProvisionServerMetrics() {
+	provisionServerMetricsOnce.Do(func() {
+		metrics.Registry.MustRegister(
+			provisionServerReady,
+			provisionServerChecksumDiscoveryDuration,
+			provisionServerImageSize,
+		)
+	})
+}
+
+// recordBaremetalSetHostMetrics - Refresh the per provisioning state host counts of an OpenStackBaremetalSet
+func recordBaremetalSetHostMetrics(instance *baremetalv1.OpenStackBaremetalSet) {
+	baremetalSetHosts.DeletePartialMatch(prometheus.Labels{"namespace": instance.Namespace, "name": instance.Name})
+
+	counts := map[baremetalv1.ProvisioningState]int{}
+	for _, bmhStatus := range instance.Status.BaremetalHosts {
+		counts[bmhStatus.ProvisioningState]++
+	}
+	for state, count := range counts {
+		baremetalSetHosts.WithLabelValues(instance.Namespace, instance.Name, string(state)).Set(float64(count))
+	}
+}
+
+// recordBaremetalSetMatchingBmhMetrics - Refresh the available and consumed counts of the BMHs matching the
+// OpenStackBaremetalSet's bmhLabelSelector
+func recordBaremetalSetMatchingBmhMetrics(instance *baremetalv1.OpenStackBaremetalSet, bmhs *metal3v1.BareMetalHostList) {
+	available := 0
+	consumed := 0
+	for _, bmh := range bmhs.Items {
+		if bmh.Spec.ConsumerRef != nil {
+			consumed++
+		} else if bmh.Status.Provisioning.State == metal3v1.StateAvailable {
+			available++
+		}
+	}
+
+	baremetalSetMatchingBmhs.WithLabelValues(instance.Namespace, instance.Name, bmhMetricStatusAvailable).Set(float64(available))
+	baremetalSetMatchingBmhs.WithLabelValues(instance.Namespace, instance.Name, bmhMetricStatusConsumed).Set(float64(consumed))
+}
+
+// deleteBaremetalSetMetrics - Drop all series of a deleted OpenStackBaremetalSet
+func deleteBaremetalSetMetrics(instance *baremetalv1.OpenStackBaremetalSet) {
+	labels := prometheus.Labels{"namespace": instance.Namespace, "name": instance.Name}
+	baremetalSetHosts.DeletePartialMatch(labels)
+	baremetalSetHostProvisioningDuration.DeletePartialMatch(labels)
+	baremetalSetMatchingBmhs.DeletePartialMatch(labels)
+}
+
+// recordProvisionServerMetrics - Refresh the readiness and image size of an OpenStackProvisionServer
+func recordProvisionServerMetrics(instance *baremetalv1.OpenStackProvisionServer) {
+	ready := 0.0
+	if instance.IsReady() {
+		ready = 1.0
+	}
+	provisionServerReady.WithLabelValues(instance.Namespace, instance.Name).Set(ready)
+
+	provisionServerImageSize.DeletePartialMatch(prometheus.Labels{"namespace": instance.Namespace, "name": instance.Name})
+	if instance.Status.OSImageSize > 0 {
+		provisionServerImageSize.WithLabelValues(instance.Namespace, instance.Name, instance.Spec.OSImage).Set(float64(instance.Status.OSImageSize))
+	}
+}
+
+// deleteProvisionServerMetrics - Drop all series of a deleted OpenStackProvisionServer
+func deleteProvisionServerMetrics(instance *baremetalv1.OpenStackProvisionServer) {
+	labels := prometheus.Labels{"namespace": instance.Namespace, "name": instance.Name}
+	provisionServerReady.DeletePartialMatch(labels)
+	provisionServerChecksumDiscoveryDuration.DeletePartialMatch(labels)
+	provisionServerImageSize.DeletePartialMatch(labels)
+}
@@ -22,14 +22,18 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -61,9 +65,12 @@ var (
 // OpenStackBaremetalSetReconciler reconciles a OpenStackBaremetalSet object
 type OpenStackBaremetalSetReconciler struct {
 	client.Client
-	Kclient kubernetes.Interface
-	Log     logr.Logger
-	Scheme  *runtime.Scheme
+	Kclient  kubernetes.Interface
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// BmhScorer - Ranks the BaremetalHosts allocated on scale-up, BinPackingBmhScorer when unset
+	BmhScorer baremetalv1.BmhScorer
 }
 
 // +kubebuilder:rbac:groups=baremetal.openstack.org,resources=openstackbaremetalsets,verbs=get;list;watch;create;update;patch;delete
@@ -76,6 +83,7 @@ type OpenStackBaremetalSetReconciler struct {
 // +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts/status,verbs=get
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=create;delete;get;list;patch;update;watch
 // +kubebuilder:rbac:groups=core,resources=secrets/finalizers,verbs=create;delete;get;list;patch;update;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile -
 func (r *OpenStackBaremetalSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, _err error) {
@@ -147,6 +155,10 @@ func (r *OpenStackBaremetalSetReconciler) Reconcile(ctx context.Context, req ctr
 				instance.Status.Conditions.Mirror(condition.ReadyCondition))
 		}
 
+		if instance.DeletionTimestamp.IsZero() {
+			recordBaremetalSetHostMetrics(instance)
+		}
+
 		// Update status only if no panic occurred
 		err := helper.PatchInstance(ctx, instance)
 		if err != nil {
@@ -192,9 +204,11 @@ func (r *OpenStackBaremetalSetReconciler) Reconcile(ctx context.Context, req ctr
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *OpenStackBaremetalSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	registerBaremetalSetMetrics()
+
 	groupLabel := labels.GetGroupLabel(baremetalv1.ServiceName)
 
-	openshiftMachineAPIBareMetalHostsFn := handler.EnqueueRequestsFromMapFunc(func(_ context.Context, o client.Object) []reconcile.Request {
+	openshiftMachineAPIBareMetalHostsFn := handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, o client.Object) []reconcile.Request {
 		result := []reconcile.Request{}
 		label := o.GetLabels()
 		// verify object has ownerUIDLabelSelector
@@ -207,6 +221,26 @@ func (r *OpenStackBaremetalSetReconciler) SetupWithManager(mgr ctrl.Manager) err
 				Name:      label[labels.GetOwnerNameLabelSelector(groupLabel)],
 			}
 			result = append(result, reconcile.Request{NamespacedName: name})
+		} else {
+			// Not (yet) allocated to any OpenStackBaremetalSet, but it might count
+			// toward the capacity of those whose bmhLabelSelector it matches
+			bmSets := &baremetalv1.OpenStackBaremetalSetList{}
+			if err := r.List(ctx, bmSets); err != nil {
+				r.Log.Error(err, "Unable to list OpenStackBaremetalSets")
+				return nil
+			}
+			for _, bmSet := range bmSets.Items {
+				if bmSet.Spec.BmhNamespace != o.GetNamespace() {
+					continue
+				}
+				selector, err := bmSet.Spec.BmhSelector()
+				if err != nil {
+					continue
+				}
+				if selector.Matches(k8s_labels.Set(label)) {
+					result = append(result, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&bmSet)})
+				}
+			}
 		}
 		if len(result) > 0 {
 			return result
@@ -230,11 +264,28 @@ func statusChangePredicate() predicate.Predicate {
 			if !okOld || !okNew {
 				return false
 			}
-			return !reflect.DeepEqual(oldObj.Status, newObj.Status) // Only trigger if status changed
+			// A BMH not allocated to any OpenStackBaremetalSet only matters for
+			// the capacity of the sets, so only trigger if anything changed that
+			// decides whether it can be allocated
+			if !baremetalHostOwned(oldObj) && !baremetalHostOwned(newObj) {
+				return oldObj.Status.Provisioning.State != newObj.Status.Provisioning.State ||
+					!reflect.DeepEqual(oldObj.Labels, newObj.Labels) ||
+					!reflect.DeepEqual(oldObj.Spec.ConsumerRef, newObj.Spec.ConsumerRef) ||
+					!reflect.DeepEqual(oldObj.Spec.Image, newObj.Spec.Image) ||
+					!reflect.DeepEqual(oldObj.Status.HardwareDetails, newObj.Status.HardwareDetails)
+			}
+			// Trigger if status changed, if anything changed that decides whether
+			// the BMH can be allocated to an OpenStackBaremetalSet, or if it got
+			// annotated (e.g. for re-image)
+			return !reflect.DeepEqual(oldObj.Status, newObj.Status) ||
+				!reflect.DeepEqual(oldObj.Labels, newObj.Labels) ||
+				!reflect.DeepEqual(oldObj.Annotations, newObj.Annotations) ||
+				!reflect.DeepEqual(oldObj.Spec.ConsumerRef, newObj.Spec.ConsumerRef) ||
+				!reflect.DeepEqual(oldObj.Spec.Image, newObj.Spec.Image)
 		},
 
 		CreateFunc: func(_ event.CreateEvent) bool {
-			return false // Ignore create events
+			return true // A new BMH may add capacity to OpenStackBaremetalSets
 		},
 
 		DeleteFunc: func(_ event.DeleteEvent) bool {
@@ -247,11 +298,17 @@ func statusChangePredicate() predicate.Predicate {
 	}
 }
 
+// baremetalHostOwned returns true if the BMH is allocated to an OpenStackBaremetalSet
+func baremetalHostOwned(bmh *metal3v1.BareMetalHost) bool {
+	_, ok := bmh.Labels[labels.GetOwnerUIDLabelSelector(labels.GetGroupLabel(baremetalv1.ServiceName))]
+	return ok
+}
+
 func (r *OpenStackBaremetalSetReconciler) reconcileDelete(ctx context.Context, instance *baremetalv1.OpenStackBaremetalSet, helper *helper.Helper) (ctrl.Result, error) {
 	r.Log.Info(fmt.Sprintf("Reconciling OpenStackBaremetalSet '%s' delete", instance.Name))
 
 	// Clean up resources used by the operator
-	// BareMetalHost resources in the namespace (don't delete, just deprovision)
+	// BareMetalHost resources in the namespace (don't delete, just deprovision or release)
 	err := r.baremetalHostCleanup(ctx, helper, instance)
 	if err != nil && !k8s_errors.IsNotFound(err) {
 		// ignore not found errors if the object is already gone
@@ -259,6 +316,7 @@ func (r *OpenStackBaremetalSetReconciler) reconcileDelete(ctx context.Context, i
 	}
 
 	controllerutil.RemoveFinalizer(instance, helper.GetFinalizer())
+	deleteBaremetalSetMetrics(instance)
 	r.Log.Info(fmt.Sprintf("Reconciled OpenStackBaremetalSet '%s' delete successfully", instance.Name))
 
 	return ctrl.Result{}, nil
@@ -396,16 +454,28 @@ func (r *OpenStackBaremetalSetReconciler) reconcileNormal(ctx context.Context, i
 	// normal reconcile tasks
 	//
 
+	//
+	// refresh the preview of BMHs this set could still scale up onto
+	//
+	matchingBmhs, err := baremetalv1.GetBaremetalSetBaremetalHosts(ctx, helper.GetClient(), instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	instance.Status.Capacity = baremetalv1.GetBaremetalSetCapacity(instance, matchingBmhs)
+	// refresh capacity - end
+
 	//
 	// either find the provided provision server or create a new one
 	//
 	provisionServer := &baremetalv1.OpenStackProvisionServer{}
+	// The provision servers of the archImages, by CPU arch, "" for the default one
+	provisionServers := openstackbaremetalset.ProvisionServers{}
 
 	if instance.Spec.OSImageDeploymentType == baremetalv1.OSImageDeploymentTypeSelfExtracting {
 		// SelfExtracting mode: create or use provision server
 		// TODO: webook should validate that either ProvisionServerName or OSImage is set in the instance spec
 		if instance.Spec.ProvisionServerName == "" {
-			provisionServer, err = r.provisionServerCreateOrUpdate(ctx, helper, instance)
+			provisionServer, err = r.provisionServerCreateOrUpdate(ctx, helper, instance, "")
 		} else {
 			// Clean-up any existing OsProvServer that we may have dynamically created for this OsBaremetalSet,
 			// since we are instead relying on a pre-existing OsProvServer...but only do this if the user hasn't,
@@ -446,24 +516,48 @@ func (r *OpenStackBaremetalSetReconciler) reconcileNormal(ctx context.Context, i
 			return ctrl.Result{}, err
 		}
 
-		if provisionServer.Status.LocalImageURL == "" {
-			instance.Status.Conditions.Set(condition.FalseCondition(
-				baremetalv1.OpenStackBaremetalSetProvServerReadyCondition,
-				condition.RequestedReason,
-				condition.SeverityInfo,
-				baremetalv1.OpenStackBaremetalSetProvServerReadyRunningMessage))
-			l.Info("OpenStackProvisionServer LocalImageURL not yet available", "OpenStackProvisionServer", provisionServer.Name)
-			return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+		provisionServers[""] = provisionServer
+
+		// Every CPU arch with its own image gets its own provision server
+		for arch := range instance.Spec.ArchImages {
+			provisionServers[arch], err = r.provisionServerCreateOrUpdate(ctx, helper, instance, arch)
+			if err != nil {
+				instance.Status.Conditions.Set(condition.FalseCondition(
+					baremetalv1.OpenStackBaremetalSetProvServerReadyCondition,
+					condition.ErrorReason,
+					condition.SeverityWarning,
+					baremetalv1.OpenStackBaremetalSetProvServerReadyErrorMessage,
+					err.Error()))
+				return ctrl.Result{}, err
+			}
 		}
 
-		if provisionServer.Status.LocalImageChecksumURL == "" {
-			instance.Status.Conditions.Set(condition.FalseCondition(
-				baremetalv1.OpenStackBaremetalSetProvServerReadyCondition,
-				condition.RequestedReason,
-				condition.SeverityInfo,
-				baremetalv1.OpenStackBaremetalSetProvServerReadyRunningMessage))
-			l.Info("OpenStackProvisionServer LocalImageChecksumURL not yet available", "OpenStackProvisionServer", provisionServer.Name)
-			return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+		if err := r.provisionServerArchCleanup(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		for _, arch := range provisionServers.Archs() {
+			server := provisionServers[arch]
+
+			if server.Status.LocalImageURL == "" {
+				instance.Status.Conditions.Set(condition.FalseCondition(
+					baremetalv1.OpenStackBaremetalSetProvServerReadyCondition,
+					condition.RequestedReason,
+					condition.SeverityInfo,
+					baremetalv1.OpenStackBaremetalSetProvServerReadyRunningMessage))
+				l.Info("OpenStackProvisionServer LocalImageURL not yet available", "OpenStackProvisionServer", server.Name)
+				return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+			}
+
+			if server.Status.LocalImageChecksumURL == "" {
+				instance.Status.Conditions.Set(condition.FalseCondition(
+					baremetalv1.OpenStackBaremetalSetProvServerReadyCondition,
+					condition.RequestedReason,
+					condition.SeverityInfo,
+					baremetalv1.OpenStackBaremetalSetProvServerReadyRunningMessage))
+				l.Info("OpenStackProvisionServer LocalImageChecksumURL not yet available", "OpenStackProvisionServer", server.Name)
+				return ctrl.Result{RequeueAfter: time.Duration(5) * time.Second}, nil
+			}
 		}
 
 		instance.Status.Conditions.MarkTrue(baremetalv1.OpenStackBaremetalSetProvServerReadyCondition, baremetalv1.OpenStackBaremetalSetProvServerReadyMessage)
@@ -478,6 +572,9 @@ func (r *OpenStackBaremetalSetReconciler) reconcileNormal(ctx context.Context, i
 			return ctrl.Result{RequeueAfter: time.Second * 10}, nil
 		}
 		// PassThrough mode: skip provision server, mark condition as ready
+		if err := r.provisionServerArchCleanup(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
 		instance.Status.Conditions.MarkTrue(baremetalv1.OpenStackBaremetalSetProvServerReadyCondition, "Provision server not needed for PassThrough mode")
 	}
 	// handle provision server - end
@@ -522,6 +619,13 @@ func (r *OpenStackBaremetalSetReconciler) reconcileNormal(ctx context.Context, i
 	}
 	// handle BMH removal - end
 
+	// Remember the provisioning states prior to this reconcile so that transitions
+	// (a host newly failing or becoming provisioned) can be detected
+	previousStates := map[string]baremetalv1.ProvisioningState{}
+	for hostName, bmhStatus := range instance.Status.BaremetalHosts {
+		previousStates[hostName] = bmhStatus.ProvisioningState
+	}
+
 	//
 	// provision requested BMH replicas
 	//
@@ -529,7 +633,7 @@ func (r *OpenStackBaremetalSetReconciler) reconcileNormal(ctx context.Context, i
 		ctx,
 		helper,
 		instance,
-		provisionServer,
+		provisionServers,
 		sshSecret,
 		passwordSecret,
 		bmhLabels,
@@ -544,17 +648,141 @@ func (r *OpenStackBaremetalSetReconciler) reconcileNormal(ctx context.Context, i
 		return ctrl.Result{}, err
 	}
 
+	//
+	// re-image provisioned BMHs as per the reimage strategy
+	//
+	if err := r.reimageBmhs(ctx, helper, instance, provisionServers, bmhLabels); err != nil {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+			condition.ErrorReason,
+			condition.SeverityWarning,
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyErrorMessage,
+			err.Error()))
+		return ctrl.Result{}, err
+	}
+	// re-image BMHs - end
+
 	// Now calculate overall provisioning status for all requested BaremetalHosts
-	for _, bmhStatus := range instance.Status.BaremetalHosts {
-		if bmhStatus.ProvisioningState != baremetalv1.ProvisioningState(metal3v1.StateProvisioned) {
+	allProvisioned := true
+	failedHosts := []string{}
+	var requeueAfter time.Duration
+	now := time.Now()
+
+	for hostName, bmhStatus := range instance.Status.BaremetalHosts {
+		if bmhStatus.ProvisioningState == baremetalv1.ProvisioningState(metal3v1.StateProvisioned) {
+			if previousStates[hostName] != bmhStatus.ProvisioningState && bmhStatus.ProvisioningStartTime != nil {
+				baremetalSetHostProvisioningDuration.WithLabelValues(instance.Namespace, instance.Name).Observe(
+					now.Sub(bmhStatus.ProvisioningStartTime.Time).Seconds())
+			}
+			continue
+		}
+		allProvisioned = false
+
+		failure, remaining := openstackbaremetalset.BaremetalHostProvisioningFailure(instance, bmhStatus, now)
+		if failure == "" {
+			if remaining > 0 && (requeueAfter == 0 || remaining < requeueAfter) {
+				requeueAfter = remaining
+			}
+			continue
+		}
+
+		failedHosts = append(failedHosts, hostName)
+		bmhStatus.ProvisioningState = baremetalv1.ProvisioningStateFailed
+		instance.Status.BaremetalHosts[hostName] = bmhStatus
+
+		if previousStates[hostName] != baremetalv1.ProvisioningStateFailed {
+			l.Info("BaremetalHost provisioning failed", "BMH", bmhStatus.BmhRef, "Hostname", hostName, "Reason", failure)
+			bmh := &metal3v1.BareMetalHost{}
+			err := helper.GetClient().Get(ctx, types.NamespacedName{Name: bmhStatus.BmhRef, Namespace: instance.Spec.BmhNamespace}, bmh)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			openstackbaremetalset.RecordBaremetalHostEvent(r.Recorder, instance, bmh, corev1.EventTypeWarning,
+				string(baremetalv1.OpenStackBaremetalSetBmhProvisioningFailedReason),
+				"BaremetalHost %s for host %s failed to provision: %s", bmhStatus.BmhRef, hostName, failure)
+		}
+	}
+
+	if len(failedHosts) > 0 {
+		sort.Strings(failedHosts)
+
+		// Hosts pinned with bmhName or adopted can't get another BMH, so they are only reported as failed
+		replaceableHosts := []string{}
+		for _, hostName := range failedHosts {
+			compute := instance.Spec.BaremetalHosts[hostName]
+			if compute.BmhName == "" && compute.AdoptBmh == "" && !instance.Status.BaremetalHosts[hostName].Adopted {
+				replaceableHosts = append(replaceableHosts, hostName)
+			}
+		}
+
+		if instance.Spec.Remediation != nil && instance.Spec.Remediation.ReplaceFailedHosts && len(replaceableHosts) > 0 {
+			if err := r.replaceFailedBmhs(ctx, helper, instance, replaceableHosts); err != nil {
+				instance.Status.Conditions.Set(condition.FalseCondition(
+					baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+					condition.ErrorReason,
+					condition.SeverityWarning,
+					baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyErrorMessage,
+					err.Error()))
+				return ctrl.Result{}, err
+			}
 			instance.Status.Conditions.Set(condition.FalseCondition(
 				baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
-				condition.RequestedReason,
+				baremetalv1.OpenStackBaremetalSetBmhReplacedReason,
 				condition.SeverityInfo,
-				baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyRunningMessage))
-			return ctrl.Result{}, nil
+				baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyReplacingMessage,
+				strings.Join(replaceableHosts, ", ")))
+			// Requeue so that replacement BMHs get allocated to the released hostnames
+			return ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		}
+
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningFailedReason,
+			condition.SeverityError,
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyFailedMessage,
+			strings.Join(failedHosts, ", ")))
+		return ctrl.Result{}, nil
+	}
+
+	// Hosts held back by the paused flag or maxConcurrentProvisioning have no BMH allocated yet
+	waitingHosts := []string{}
+	for hostName := range instance.Spec.BaremetalHosts {
+		if _, ok := instance.Status.BaremetalHosts[hostName]; !ok {
+			waitingHosts = append(waitingHosts, hostName)
 		}
 	}
+	sort.Strings(waitingHosts)
+
+	if len(waitingHosts) > 0 && instance.Spec.Paused {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningPausedReason,
+			condition.SeverityInfo,
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyPausedMessage,
+			strings.Join(waitingHosts, ", ")))
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	if len(waitingHosts) > 0 {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyWaitingMessage,
+			strings.Join(waitingHosts, ", ")))
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	if !allProvisioned {
+		instance.Status.Conditions.Set(condition.FalseCondition(
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+			condition.RequestedReason,
+			condition.SeverityInfo,
+			baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyRunningMessage))
+		// Come back once the earliest provisioning timeout expires, in case no
+		// BMH status change triggers a reconcile before then
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
 	instance.Status.Conditions.MarkTrue(baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition, baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyMessage)
 	// provision BMHs - end
 
@@ -568,17 +796,31 @@ func (r *OpenStackBaremetalSetReconciler) reconcileNormal(ctx context.Context, i
 	return ctrl.Result{}, nil
 }
 
+// provisionServerCreateOrUpdate - Create or update the provision server of the set for the archImages of a CPU
+// arch, or the default one for arch ""
 func (r *OpenStackBaremetalSetReconciler) provisionServerCreateOrUpdate(
 	ctx context.Context,
 	helper *helper.Helper,
 	instance *baremetalv1.OpenStackBaremetalSet,
+	arch string,
 ) (*baremetalv1.OpenStackProvisionServer, error) {
 	l := log.FromContext(ctx)
 
+	osImage := instance.Spec.OSImage
+	osContainerImageURL := instance.Spec.OSContainerImageURL
+	if archImage, ok := instance.Spec.ArchImages[arch]; ok {
+		if archImage.OSImage != "" {
+			osImage = archImage.OSImage
+		}
+		if archImage.OSContainerImageURL != "" {
+			osContainerImageURL = archImage.OSContainerImageURL
+		}
+	}
+
 	// Next deploy the provisioning image (Apache) server
 	provisionServer := &baremetalv1.OpenStackProvisionServer{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      instance.Name + "-provisionserver",
+			Name:      provisionServerName(instance, arch),
 			Namespace: instance.Namespace,
 		},
 	}
@@ -596,8 +838,8 @@ func (r *OpenStackBaremetalSetReconciler) provisionServerCreateOrUpdate(
 		if err != nil {
 			return err
 		}
-		provisionServer.Spec.OSImage = instance.Spec.OSImage
-		provisionServer.Spec.OSContainerImageURL = instance.Spec.OSContainerImageURL
+		provisionServer.Spec.OSImage = osImage
+		provisionServer.Spec.OSContainerImageURL = osContainerImageURL
 		provisionServer.Spec.ApacheImageURL = instance.Spec.ApacheImageURL
 		provisionServer.Spec.AgentImageURL = instance.Spec.AgentImageURL
 		provisionServer.Spec.NodeSelector = instance.Spec.ProvisonServerNodeSelector
@@ -620,6 +862,52 @@ func (r *OpenStackBaremetalSetReconciler) provisionServerCreateOrUpdate(
 	return provisionServer, nil
 }
 
+// provisionServerName - The name of the provision server created for the archImages of a CPU arch, or of the
+// default one for arch ""
+func provisionServerName(instance *baremetalv1.OpenStackBaremetalSet, arch string) string {
+	if arch == "" {
+		return fmt.Sprintf("%s-provisionserver", instance.Name)
+	}
+	// Object names can't contain the underscore of x86_64
+	return fmt.Sprintf("%s-provisionserver-%s", instance.Name, strings.ReplaceAll(arch, "_", "-"))
+}
+
+// provisionServerArchCleanup - Delete the provision servers created for CPU archs no longer in archImages, or
+// all of them in PassThrough mode
+func (r *OpenStackBaremetalSetReconciler) provisionServerArchCleanup(
+	ctx context.Context,
+	instance *baremetalv1.OpenStackBaremetalSet,
+) error {
+	l := log.FromContext(ctx)
+
+	wanted := map[string]bool{}
+	if instance.Spec.OSImageDeploymentType == baremetalv1.OSImageDeploymentTypeSelfExtracting {
+		for arch := range instance.Spec.ArchImages {
+			wanted[provisionServerName(instance, arch)] = true
+		}
+	}
+
+	provisionServers := &baremetalv1.OpenStackProvisionServerList{}
+	if err := r.List(ctx, provisionServers, client.InNamespace(instance.Namespace)); err != nil {
+		return err
+	}
+
+	for i := range provisionServers.Items {
+		provisionServer := &provisionServers.Items[i]
+		if wanted[provisionServer.Name] || !metav1.IsControlledBy(provisionServer, instance) ||
+			!strings.HasPrefix(provisionServer.Name, provisionServerName(instance, "")+"-") {
+			continue
+		}
+
+		if err := r.Delete(ctx, provisionServer); err != nil && !k8s_errors.IsNotFound(err) {
+			return err
+		}
+		l.Info("OpenStackProvisionServer successfully deleted", "OpenStackProvisionServer", provisionServer.Name)
+	}
+
+	return nil
+}
+
 func (r *OpenStackBaremetalSetReconciler) provisionServerDelete(
 	ctx context.Context,
 	instance *baremetalv1.OpenStackBaremetalSet,
@@ -628,7 +916,7 @@ func (r *OpenStackBaremetalSetReconciler) provisionServerDelete(
 
 	provisionServer := &baremetalv1.OpenStackProvisionServer{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-provisionserver", instance.Name),
+			Name:      provisionServerName(instance, ""),
 			Namespace: instance.Namespace,
 		},
 	}
@@ -673,6 +961,14 @@ func (r *OpenStackBaremetalSetReconciler) deleteBmh(
 		}
 
 		if !found {
+			// Keep the host until its BMH gets annotated, in case it was removed from the spec by mistake
+			_, annotated := existingBmh.Annotations[openstackbaremetalset.HostRemovalAnnotation]
+			if instance.Spec.ScaleDownPolicy == baremetalv1.ScaleDownPolicyRequireAnnotation && !annotated {
+				log.FromContext(ctx).Info("Not deprovisioning BaremetalHost removed from the spec as it is not annotated for deletion",
+					"BMH", existingBmh.Name, "Hostname", existingBmh.Labels[instanceBmhOwnershipLabelKey],
+					"Annotation", openstackbaremetalset.HostRemovalAnnotation)
+				continue
+			}
 			hostNamesToDeprovision = append(hostNamesToDeprovision, existingBmh.Labels[instanceBmhOwnershipLabelKey])
 		}
 	}
@@ -684,6 +980,7 @@ func (r *OpenStackBaremetalSetReconciler) deleteBmh(
 		err = openstackbaremetalset.BaremetalHostDeprovision(
 			ctx,
 			helper,
+			r.Recorder,
 			instance,
 			bmhStatus,
 		)
@@ -696,6 +993,141 @@ func (r *OpenStackBaremetalSetReconciler) deleteBmh(
 	return nil
 }
 
+// replaceFailedBmhs - Release (and optionally quarantine) the BaremetalHosts allocated to failed hosts, marked so
+// that the set does not allocate them again. The hosts' status entries are removed, so the next reconcile matches
+// fresh BaremetalHosts to the same hostnames and IPs.
+func (r *OpenStackBaremetalSetReconciler) replaceFailedBmhs(
+	ctx context.Context,
+	helper *helper.Helper,
+	instance *baremetalv1.OpenStackBaremetalSet,
+	hostNames []string,
+) error {
+	l := log.FromContext(ctx)
+
+	for _, hostName := range hostNames {
+		bmhStatus := instance.Status.BaremetalHosts[hostName]
+		bmh := &metal3v1.BareMetalHost{}
+		err := helper.GetClient().Get(ctx, types.NamespacedName{Name: bmhStatus.BmhRef, Namespace: instance.Spec.BmhNamespace}, bmh)
+		if err != nil {
+			return err
+		}
+
+		// Without quarantine the BaremetalHost returns to the free pool, where the set must not pick it again
+		baremetalv1.MarkBaremetalHostFailed(instance, bmh)
+		err = helper.GetClient().Update(ctx, bmh)
+		if err != nil {
+			return err
+		}
+
+		if instance.Spec.Remediation.Quarantine || len(instance.Spec.Remediation.QuarantineTaints) > 0 {
+			err := openstackbaremetalset.BaremetalHostQuarantine(ctx, helper, r.Recorder, instance, bmhStatus)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = openstackbaremetalset.BaremetalHostDeprovision(ctx, helper, r.Recorder, instance, bmhStatus)
+		if err != nil {
+			return err
+		}
+
+		l.Info("Released failed BaremetalHost for replacement", "BMH", bmhStatus.BmhRef, "Hostname", hostName)
+		openstackbaremetalset.RecordBaremetalHostEvent(r.Recorder, instance, bmh, corev1.EventTypeNormal,
+			string(baremetalv1.OpenStackBaremetalSetBmhReplacedReason),
+			"Released failed BaremetalHost %s of host %s, a replacement will be allocated", bmhStatus.BmhRef, hostName)
+	}
+
+	return nil
+}
+
+// Re-image provisioned BaremetalHosts that run an outdated image (Rolling strategy only) or that are annotated
+// for re-image, keeping at most maxUnavailable hosts of the set unprovisioned at once
+func (r *OpenStackBaremetalSetReconciler) reimageBmhs(
+	ctx context.Context,
+	helper *helper.Helper,
+	instance *baremetalv1.OpenStackBaremetalSet,
+	provisionServers openstackbaremetalset.ProvisionServers,
+	bmhLabels map[string]string,
+) error {
+	strategy := instance.Spec.ReimageStrategy
+	if strategy == nil {
+		return nil
+	}
+
+	existingBaremetalHosts, err := baremetalv1.GetBaremetalHosts(ctx, helper.GetClient(), instance.Spec.BmhNamespace, bmhLabels)
+	if err != nil {
+		return err
+	}
+	existingBmhs := map[string]*metal3v1.BareMetalHost{}
+	for i := range existingBaremetalHosts.Items {
+		existingBmhs[existingBaremetalHosts.Items[i].Name] = &existingBaremetalHosts.Items[i]
+	}
+
+	provisioned := baremetalv1.ProvisioningState(metal3v1.StateProvisioned)
+	unavailable := 0
+	candidates := []string{}
+
+	for hostName, bmhStatus := range instance.Status.BaremetalHosts {
+		// Follow the progress of the hosts being re-imaged
+		switch {
+		case bmhStatus.ReimagePhase == baremetalv1.ReimagePhaseDeprovisioning && bmhStatus.ProvisioningState != provisioned:
+			bmhStatus.ReimagePhase = baremetalv1.ReimagePhaseProvisioning
+		case bmhStatus.ReimagePhase == baremetalv1.ReimagePhaseProvisioning && bmhStatus.ProvisioningState == provisioned:
+			bmhStatus.ReimagePhase = ""
+			if bmh, ok := existingBmhs[bmhStatus.BmhRef]; ok {
+				openstackbaremetalset.RecordBaremetalHostEvent(r.Recorder, instance, bmh, corev1.EventTypeNormal,
+					openstackbaremetalset.EventReasonBmhReimaged,
+					"BaremetalHost %s of host %s re-imaged with image %s", bmh.Name, hostName,
+					openstackbaremetalset.BaremetalHostImage(instance, provisionServers, bmh).URL)
+			}
+		}
+		instance.Status.BaremetalHosts[hostName] = bmhStatus
+
+		if bmhStatus.ReimagePhase != "" || bmhStatus.ProvisioningState != provisioned {
+			unavailable++
+			continue
+		}
+
+		bmh, ok := existingBmhs[bmhStatus.BmhRef]
+		if !ok {
+			continue
+		}
+		_, annotated := bmh.Annotations[openstackbaremetalset.ReimageAnnotation]
+		desiredImage := openstackbaremetalset.BaremetalHostImage(instance, provisionServers, bmh)
+		// Adopted hosts run an image the set did not choose, so they are only re-imaged on request
+		outdated := !bmhStatus.Adopted && bmh.Spec.Image != nil &&
+			(bmh.Spec.Image.URL != desiredImage.URL || bmh.Spec.Image.Checksum != desiredImage.Checksum)
+
+		if annotated || (outdated && strategy.Type == baremetalv1.ReimageStrategyRolling) {
+			candidates = append(candidates, hostName)
+		}
+	}
+
+	// Hosts already being re-imaged carry on while paused, but no new ones are started
+	if instance.Spec.Paused {
+		return nil
+	}
+
+	// Re-image in a stable order, so that a rollout proceeds host by host
+	sort.Strings(candidates)
+
+	for _, hostName := range candidates {
+		if unavailable >= strategy.MaxUnavailable {
+			log.FromContext(ctx).Info("Waiting for hosts to be provisioned before re-imaging more",
+				"Unavailable", unavailable, "MaxUnavailable", strategy.MaxUnavailable)
+			break
+		}
+
+		err := openstackbaremetalset.BaremetalHostReimage(ctx, helper, r.Recorder, instance, instance.Status.BaremetalHosts[hostName])
+		if err != nil {
+			return err
+		}
+		unavailable++
+	}
+
+	return nil
+}
+
 func (r *OpenStackBaremetalSetReconciler) buildExistingHostBMHMap(instance *baremetalv1.OpenStackBaremetalSet,
 	existingBMHs *metal3v1.BareMetalHostList) map[string]metal3v1.BareMetalHost {
 	existingHostBMHMap := make(map[string]metal3v1.BareMetalHost)
@@ -714,24 +1146,52 @@ func (r *OpenStackBaremetalSetReconciler) ensureBaremetalHosts(
 	ctx context.Context,
 	helper *helper.Helper,
 	instance *baremetalv1.OpenStackBaremetalSet,
-	provisionServer *baremetalv1.OpenStackProvisionServer,
+	provisionServers openstackbaremetalset.ProvisionServers,
 	sshSecret *corev1.Secret,
 	passwordSecret *corev1.Secret,
 	bmhLabels map[string]string,
 	envVars *map[string]env.Setter,
 ) error {
 
-	// Get all BaremetalHosts (and, optionally, only those that match instance.Spec.BmhLabelSelector if there is one)
-	baremetalHostsList, err := baremetalv1.GetBaremetalHosts(
-		ctx,
-		helper.GetClient(),
-		instance.Spec.BmhNamespace,
-		instance.Spec.BmhLabelSelector,
-	)
+	// Get all BaremetalHosts (and, optionally, only those that match instance.Spec.BmhLabelSelector and
+	// instance.Spec.BmhMatchExpressions if there are any)
+	baremetalHostsList, err := baremetalv1.GetBaremetalSetBaremetalHosts(ctx, helper.GetClient(), instance)
 	if err != nil {
 		return err
 	}
 
+	recordBaremetalSetMatchingBmhMetrics(instance, baremetalHostsList)
+
+	// Adopt the already provisioned BaremetalHosts requested via adoptBmh, which do not need to match
+	// the bmhLabelSelector
+	if !instance.Spec.Paused {
+		allBaremetalHosts, err := baremetalv1.GetBaremetalHosts(ctx, helper.GetClient(), instance.Spec.BmhNamespace, nil)
+		if err != nil {
+			return err
+		}
+		baremetalSets := &baremetalv1.OpenStackBaremetalSetList{}
+		if err := helper.GetClient().List(ctx, baremetalSets); err != nil {
+			return err
+		}
+		adoptedHostBMHMap, err := baremetalv1.VerifyBaremetalSetAdoption(instance, allBaremetalHosts, baremetalSets.Items)
+		if err != nil {
+			return err
+		}
+		for hostName, bmh := range adoptedHostBMHMap {
+			err := openstackbaremetalset.BaremetalHostAdopt(
+				ctx,
+				helper,
+				r.Recorder,
+				instance,
+				bmh.Name,
+				hostName,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	// Get all existing BaremetalHosts of this CR
 	existingBaremetalHosts, err := baremetalv1.GetBaremetalHosts(ctx, helper.GetClient(), instance.Spec.BmhNamespace, bmhLabels)
 	if err != nil {
@@ -739,23 +1199,43 @@ func (r *OpenStackBaremetalSetReconciler) ensureBaremetalHosts(
 	}
 
 	// Verify that we have enough hosts with the right hardware reqs available for scaling-up
-	selectedHostBMHMap, err := baremetalv1.VerifyBaremetalSetScaleUp(log.FromContext(ctx), instance, baremetalHostsList, existingBaremetalHosts)
+	scorer := r.BmhScorer
+	if scorer == nil {
+		scorer = baremetalv1.BinPackingBmhScorer{}
+	}
+	selectedHostBMHMap, err := baremetalv1.VerifyBaremetalSetScaleUp(log.FromContext(ctx), scorer, instance, baremetalHostsList, existingBaremetalHosts)
 	if err != nil {
 		return err
 	}
 
+	// Hold back new hosts while the set is paused or has too many hosts provisioning already
+	selectedHostBMHMap = limitBmhAllocations(instance, selectedHostBMHMap)
+
+	for hostName, bmh := range selectedHostBMHMap {
+		openstackbaremetalset.RecordBaremetalHostEvent(r.Recorder, instance, &bmh, corev1.EventTypeNormal,
+			openstackbaremetalset.EventReasonBmhAllocated,
+			"Allocated BaremetalHost %s to host %s: %s", bmh.Name, hostName, bmhSelectionReason(instance, hostName))
+	}
+
 	existingHostBMHMap := r.buildExistingHostBMHMap(instance, existingBaremetalHosts)
 	selectedHostBMHMap = util.MergeMaps(selectedHostBMHMap, existingHostBMHMap)
 
+	// Hosts removed from the spec but kept by the scale-down policy are left as they are
+	for hostName := range selectedHostBMHMap {
+		if _, found := instance.Spec.BaremetalHosts[hostName]; !found {
+			delete(selectedHostBMHMap, hostName)
+		}
+	}
+
 	for desiredHostName, bmh := range selectedHostBMHMap {
 		err := openstackbaremetalset.BaremetalHostProvision(
 			ctx,
 			helper,
+			r.Recorder,
 			instance,
 			bmh.Name,
 			desiredHostName,
-			instance.Spec.BaremetalHosts[desiredHostName].CtlPlaneIP, // ctlPlaneIP
-			provisionServer,
+			provisionServers,
 			sshSecret,
 			passwordSecret,
 			envVars,
@@ -768,7 +1248,78 @@ func (r *OpenStackBaremetalSetReconciler) ensureBaremetalHosts(
 	return nil
 }
 
-// Deprovision all associated BaremetalHosts for this OpenStackBaremetalSet via Metal3
+// limitBmhAllocations - Keep, in hostname order, only the new host allocations the paused flag and
+// maxConcurrentProvisioning of the set allow for. The remaining hosts get allocated in later reconciles
+func limitBmhAllocations(
+	instance *baremetalv1.OpenStackBaremetalSet,
+	selectedHostBMHMap map[string]metal3v1.BareMetalHost,
+) map[string]metal3v1.BareMetalHost {
+	if instance.Spec.Paused {
+		return map[string]metal3v1.BareMetalHost{}
+	}
+	if instance.Spec.MaxConcurrentProvisioning == 0 || len(selectedHostBMHMap) == 0 {
+		return selectedHostBMHMap
+	}
+
+	// Failed hosts are not counted, as Metal3 is no longer working on them
+	provisioning := 0
+	for _, bmhStatus := range instance.Status.BaremetalHosts {
+		if bmhStatus.ProvisioningState != baremetalv1.ProvisioningState(metal3v1.StateProvisioned) &&
+			bmhStatus.ProvisioningState != baremetalv1.ProvisioningStateFailed {
+			provisioning++
+		}
+	}
+
+	hostNames := make([]string, 0, len(selectedHostBMHMap))
+	for hostName := range selectedHostBMHMap {
+		hostNames = append(hostNames, hostName)
+	}
+	sort.Strings(hostNames)
+
+	allowed := map[string]metal3v1.BareMetalHost{}
+	for _, hostName := range hostNames {
+		if provisioning >= instance.Spec.MaxConcurrentProvisioning {
+			break
+		}
+		allowed[hostName] = selectedHostBMHMap[hostName]
+		provisioning++
+	}
+
+	return allowed
+}
+
+// bmhSelectionReason - Describe why a BaremetalHost was eligible for a host of the set
+func bmhSelectionReason(instance *baremetalv1.OpenStackBaremetalSet, hostName string) string {
+	reasons := []string{"it is available and not consumed"}
+
+	if len(instance.Spec.BmhLabelSelector) > 0 || len(instance.Spec.BmhMatchExpressions) > 0 {
+		if selector, err := instance.Spec.BmhSelector(); err == nil {
+			reasons = append(reasons, fmt.Sprintf("matches bmhLabelSelector %s", selector))
+		}
+	}
+	if instance.Spec.BaremetalHosts[hostName].BmhName != "" {
+		reasons = append(reasons, "is pinned by bmhName")
+	}
+	if compute := instance.Spec.BaremetalHosts[hostName]; len(compute.BmhLabelSelector) > 0 || len(compute.BmhMatchExpressions) > 0 {
+		if selector, err := compute.BmhSelector(); err == nil {
+			reasons = append(reasons, fmt.Sprintf("matches host bmhLabelSelector %s", selector))
+		}
+	}
+	if instance.Spec.BaremetalHosts[hostName].HardwareReqs != nil {
+		reasons = append(reasons, "satisfies host hardwareReqs")
+	} else if instance.Spec.HardwareReqs != (baremetalv1.HardwareReqs{}) {
+		reasons = append(reasons, "satisfies hardwareReqs")
+	}
+	for _, constraint := range instance.Spec.TopologySpreadConstraints {
+		reasons = append(reasons, fmt.Sprintf("keeps the hosts spread across %s within maxSkew %d",
+			constraint.TopologyKey, constraint.MaxSkew))
+	}
+
+	return strings.Join(reasons, ", ")
+}
+
+// Deprovision all associated BaremetalHosts for this OpenStackBaremetalSet via Metal3, or only release
+// them with the Retain deletion policy
 func (r *OpenStackBaremetalSetReconciler) baremetalHostCleanup(
 	ctx context.Context,
 	helper *helper.Helper,
@@ -776,7 +1327,13 @@ func (r *OpenStackBaremetalSetReconciler) baremetalHostCleanup(
 ) error {
 	if instance.Status.BaremetalHosts != nil {
 		for _, bmh := range instance.Status.BaremetalHosts {
-			if err := openstackbaremetalset.BaremetalHostDeprovision(ctx, helper, instance, bmh); err != nil {
+			if instance.Spec.DeletionPolicy == baremetalv1.DeletionPolicyRetain {
+				if err := openstackbaremetalset.BaremetalHostRelease(ctx, helper, r.Recorder, instance, bmh); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := openstackbaremetalset.BaremetalHostDeprovision(ctx, helper, r.Recorder, instance, bmh); err != nil {
 				return err
 			}
 		}
@@ -166,6 +166,10 @@ func (r *OpenStackProvisionServerReconciler) Reconcile(ctx context.Context, req
 				instance.Status.Conditions.Mirror(condition.ReadyCondition))
 		}
 
+		if instance.DeletionTimestamp.IsZero() {
+			recordProvisionServerMetrics(instance)
+		}
+
 		// Update status only if no panic occurred
 		err := helper.PatchInstance(ctx, instance)
 		if err != nil {
@@ -269,6 +273,8 @@ func (r *OpenStackProvisionServerReconciler) Reconcile(ctx context.Context, req
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *OpenStackProvisionServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	registerProvisionServerMetrics()
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&baremetalv1.OpenStackProvisionServer{}).
 		Owns(&appsv1.Deployment{}).
@@ -283,6 +289,7 @@ func (r *OpenStackProvisionServerReconciler) reconcileDelete(_ context.Context,
 	r.Log.Info(fmt.Sprintf("Reconciling OpenStackProvisionServer '%s' delete", instance.Name))
 
 	controllerutil.RemoveFinalizer(instance, helper.GetFinalizer())
+	deleteProvisionServerMetrics(instance)
 	r.Log.Info(fmt.Sprintf("Reconciled OpenStackProvisionServer '%s' delete successfully", instance.Name))
 
 	return ctrl.Result{}, nil
@@ -568,6 +575,13 @@ func (r *OpenStackProvisionServerReconciler) reconcileNormal(ctx context.Context
 	}
 
 	if instance.Status.LocalImageChecksumURL != "" {
+		// The checksum condition has been "running" since the checksum job was started, so its
+		// last transition marks the start of the discovery
+		checksumCondition := instance.Status.Conditions.Get(baremetalv1.OpenStackProvisionServerChecksumReadyCondition)
+		if checksumCondition != nil && checksumCondition.Reason == condition.RequestedReason {
+			provisionServerChecksumDiscoveryDuration.WithLabelValues(instance.Namespace, instance.Name).Observe(
+				time.Since(checksumCondition.LastTransitionTime.Time).Seconds())
+		}
 		instance.Status.Conditions.MarkTrue(baremetalv1.OpenStackProvisionServerChecksumReadyCondition, baremetalv1.OpenStackProvisionServerChecksumReadyMessage)
 	} else {
 		instance.Status.Conditions.Set(condition.FalseCondition(
@@ -4,9 +4,11 @@ package openstackbaremetalset
 import (
 	"context"
 	"fmt"
-	"net"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/openstack-k8s-operators/lib-common/modules/common/backup"
@@ -17,7 +19,10 @@ import (
 	"github.com/openstack-k8s-operators/lib-common/modules/common/util"
 	baremetalv1 "github.com/openstack-k8s-operators/openstack-baremetal-operator/api/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
@@ -26,11 +31,11 @@ import (
 func BaremetalHostProvision(
 	ctx context.Context,
 	helper *helper.Helper,
+	recorder record.EventRecorder,
 	instance *baremetalv1.OpenStackBaremetalSet,
 	bmh string,
 	hostName string,
-	ctlPlaneIP string,
-	provServer *baremetalv1.OpenStackProvisionServer,
+	provServers ProvisionServers,
 	sshSecret *corev1.Secret,
 	passwordSecret *corev1.Secret,
 	envVars *map[string]env.Setter,
@@ -42,24 +47,32 @@ func BaremetalHostProvision(
 	var ok bool
 	var bmhStatus baremetalv1.HostStatus
 
-	if bmhStatus, ok = instance.Status.BaremetalHosts[hostName]; !ok {
+	bmhStatus, ok = instance.Status.BaremetalHosts[hostName]
+	isNewHost := !ok
+	if isNewHost {
 		bmhStatus = baremetalv1.HostStatus{
 
 			IPStatus: baremetalv1.IPStatus{
 				Hostname:    hostName,
 				BmhRef:      bmh,
-				IPAddresses: map[string]string{},
+				IPAddresses: baremetalv1.BaremetalHostIPAddresses(instance, hostName),
 			},
 		}
-		bmhStatus.IPAddresses["ctlplane"] = ctlPlaneIP
 	}
+	// The BaremetalHost, whose hardware details the networkData may refer to
+	foundBaremetalHost := &metal3v1.BareMetalHost{}
+	err := helper.GetClient().Get(ctx, types.NamespacedName{Name: bmh, Namespace: instance.Spec.BmhNamespace}, foundBaremetalHost)
+	if err != nil {
+		return err
+	}
+
 	// Instance UserData/NetworkData
 	userDataSecret := instance.Spec.BaremetalHosts[hostName].UserData
 	networkDataSecret := instance.Spec.BaremetalHosts[hostName].NetworkData
 
 	sts := []util.Template{}
-	// User data cloud-init secret
-	if userDataSecret == nil {
+	// User data cloud-init secret, adopted hosts keep the one they were deployed with
+	if userDataSecret == nil && !bmhStatus.Adopted {
 		templateParameters := make(map[string]any)
 		templateParameters["AuthorizedKeys"] = strings.TrimSuffix(string(sshSecret.Data["authorized_keys"]), "\n")
 		templateParameters["HostName"] = hostName
@@ -100,48 +113,89 @@ func BaremetalHostProvision(
 
 	}
 
-	if networkDataSecret == nil {
-
-		// Check IP version and set template variables accordingly
-		ipAddr, ipNet, err := net.ParseCIDR(ctlPlaneIP)
-		if err != nil {
-			return err
-		}
-
-		CtlplaneIPVersion := "ipv6"
-		if ipAddr.To4() != nil {
-			CtlplaneIPVersion = "ipv4"
-		}
+	if networkDataSecret == nil && !bmhStatus.Adopted {
 
 		templateParameters := make(map[string]any)
-		templateParameters["CtlplaneIpVersion"] = CtlplaneIPVersion
-		templateParameters["CtlplaneIp"] = ipAddr
 		if instance.Spec.BaremetalHosts[hostName].CtlplaneVlan != nil {
 			templateParameters["CtlplaneVlan"] = *instance.Spec.BaremetalHosts[hostName].CtlplaneVlan
 		} else if instance.Spec.CtlplaneVlan != nil {
 			templateParameters["CtlplaneVlan"] = *instance.Spec.CtlplaneVlan
 		}
+		ctlplaneInterface := instance.Spec.CtlplaneInterface
 		if instance.Spec.BaremetalHosts[hostName].CtlplaneInterface != "" {
-			templateParameters["CtlplaneInterface"] = instance.Spec.BaremetalHosts[hostName].CtlplaneInterface
-		} else {
-			templateParameters["CtlplaneInterface"] = instance.Spec.CtlplaneInterface
+			ctlplaneInterface = instance.Spec.BaremetalHosts[hostName].CtlplaneInterface
 		}
-		if instance.Spec.BaremetalHosts[hostName].CtlplaneGateway != "" {
-			templateParameters["CtlplaneGateway"] = instance.Spec.BaremetalHosts[hostName].CtlplaneGateway
-		} else {
-			templateParameters["CtlplaneGateway"] = instance.Spec.CtlplaneGateway
+		templateParameters["CtlplaneInterface"] = ctlplaneInterface
+		ctlplaneMTU := instance.Spec.CtlplaneMTU
+		if instance.Spec.BaremetalHosts[hostName].CtlplaneMTU != 0 {
+			ctlplaneMTU = instance.Spec.BaremetalHosts[hostName].CtlplaneMTU
 		}
-		// Handle bonding configuration from template spec
-		if instance.Spec.CtlplaneBond != nil {
-			templateParameters["CtlplaneBondInterfaces"] = instance.Spec.CtlplaneBond.BondInterfaces
-			if instance.Spec.CtlplaneBond.BondMode != "" {
-				templateParameters["CtlplaneBondMode"] = instance.Spec.CtlplaneBond.BondMode
+		templateParameters["CtlplaneMtu"] = ctlplaneMTU
+		// Handle bonding configuration of the host, or else from template spec
+		ctlplaneBond := baremetalv1.BaremetalHostCtlplaneBond(instance, hostName)
+		if ctlplaneBond != nil {
+			templateParameters["CtlplaneBondInterfaces"] = ctlplaneBond.BondInterfaces
+			if ctlplaneBond.BondMode != "" {
+				templateParameters["CtlplaneBondMode"] = ctlplaneBond.BondMode
+			}
+			if len(ctlplaneBond.BondOptions) > 0 {
+				templateParameters["CtlplaneBondOptions"] = networkDataBondOptions(ctlplaneBond.BondOptions)
 			}
-			if len(instance.Spec.CtlplaneBond.BondOptions) > 0 {
-				templateParameters["CtlplaneBondOptions"] = instance.Spec.CtlplaneBond.BondOptions
+		}
+
+		// Every ctlplane address (one per IP version) is a network on the same link
+		ctlplaneLinks := []string{ctlplaneInterface}
+		if ctlplaneBond != nil {
+			ctlplaneLinks = append(ctlplaneLinks, ctlplaneBond.BondInterfaces...)
+		}
+		ctlplaneLink := ctlplaneInterface
+		if vlan, ok := templateParameters["CtlplaneVlan"]; ok {
+			ctlplaneLink = fmt.Sprintf("%s.%d", ctlplaneInterface, vlan)
+			ctlplaneLinks = append(ctlplaneLinks, ctlplaneLink)
+		}
+		ctlplaneNetworks, err := networkDataForCtlplane(
+			baremetalv1.BaremetalHostCtlplaneAddresses(instance, hostName),
+			ctlplaneLink,
+			baremetalv1.BaremetalHostCtlplaneRoutes(instance, hostName),
+		)
+		if err != nil {
+			return err
+		}
+		templateParameters["CtlplaneNetworks"] = ctlplaneNetworks
+
+		// The other networks go on the ctlplane links, or on their own
+		links, dataNetworks, err := networkDataForNetworks(
+			baremetalv1.BaremetalHostNetworks(instance, hostName), ctlplaneInterface, ctlplaneLinks)
+		if err != nil {
+			return err
+		}
+		templateParameters["Links"] = links
+		templateParameters["Networks"] = dataNetworks
+		// The VLANs of the other networks on the ctlplane interface may need a bigger MTU than ctlplane
+		templateParameters["CtlplaneInterfaceMtu"] = ctlplaneInterfaceMTU(
+			baremetalv1.BaremetalHostNetworks(instance, hostName), ctlplaneInterface, ctlplaneMTU)
+
+		// The vif links may also be found by the MAC address of their NIC
+		vifs := []string{}
+		ctlplaneVif := ""
+		if ctlplaneBond != nil {
+			vifs = append(vifs, ctlplaneBond.BondInterfaces...)
+		} else {
+			vifs = append(vifs, ctlplaneInterface)
+			ctlplaneVif = ctlplaneInterface
+		}
+		for _, link := range links {
+			if link.Type == "vif" {
+				vifs = append(vifs, link.Name)
 			}
 		}
-		templateParameters["CtlplaneNetmask"] = net.IP(ipNet.Mask)
+		macAddresses, err := networkDataMACAddresses(
+			instance.Spec.InterfaceMACAddressesFrom, foundBaremetalHost, vifs, ctlplaneVif)
+		if err != nil {
+			return err
+		}
+		templateParameters["MacAddresses"] = macAddresses
+
 		if len(instance.Spec.BootstrapDNS) > 0 {
 			templateParameters["CtlplaneDns"] = instance.Spec.BootstrapDNS
 		} else {
@@ -190,10 +244,10 @@ func BaremetalHostProvision(
 	//
 	// Provision the BaremetalHost
 	//
-	foundBaremetalHost := &metal3v1.BareMetalHost{}
-	err := helper.GetClient().Get(ctx, types.NamespacedName{Name: bmh, Namespace: instance.Spec.BmhNamespace}, foundBaremetalHost)
-	if err != nil {
-		return err
+	startingProvisioning := foundBaremetalHost.Spec.ConsumerRef == nil
+	if bmhStatus.Adopted {
+		userDataSecret = foundBaremetalHost.Spec.UserData
+		networkDataSecret = foundBaremetalHost.Spec.NetworkData
 	}
 	op, err := controllerutil.CreateOrPatch(ctx, helper.GetClient(), foundBaremetalHost, func() error {
 		// Set our ownership labels so we can watch this resource and also indicate that this BMH
@@ -211,21 +265,16 @@ func BaremetalHostProvision(
 		foundBaremetalHost.Spec.AutomatedCleaningMode = metal3v1.AutomatedCleaningMode(instance.Spec.AutomatedCleaningMode)
 
 		//
-		// Ensure the image url is up to date unless already provisioned
+		// Ensure the image url is up to date unless already provisioned, or adopted
+		// and not being re-imaged
 		//
-		if foundBaremetalHost.Status.Provisioning.State != metal3v1.StateProvisioned {
-			if instance.Spec.OSImageDeploymentType == baremetalv1.OSImageDeploymentTypePassThrough {
-				// PassThrough mode: use container URL directly
-				foundBaremetalHost.Spec.Image = &metal3v1.Image{
-					URL: instance.Spec.OSContainerImageURL,
-				}
-			} else {
-				// SelfExtracting mode: use provision server
-				foundBaremetalHost.Spec.Image = &metal3v1.Image{
-					URL:          provServer.Status.LocalImageURL,
-					Checksum:     provServer.Status.LocalImageChecksumURL,
-					ChecksumType: provServer.Status.OSImageChecksumType,
-				}
+		adoptedAsIs := bmhStatus.Adopted && bmhStatus.ReimagePhase == ""
+		if foundBaremetalHost.Status.Provisioning.State != metal3v1.StateProvisioned && !adoptedAsIs {
+			foundBaremetalHost.Spec.Image = BaremetalHostImage(instance, provServers, foundBaremetalHost)
+
+			// Keep any hints set on the BMH itself unless the set has some for it
+			if hints := baremetalv1.BaremetalHostRootDeviceHints(instance, hostName, foundBaremetalHost); hints != nil {
+				foundBaremetalHost.Spec.RootDeviceHints = hints
 			}
 		}
 
@@ -235,19 +284,7 @@ func BaremetalHostProvision(
 		if foundBaremetalHost.Spec.ConsumerRef == nil {
 			foundBaremetalHost.Spec.Online = true
 			foundBaremetalHost.Spec.ConsumerRef = &corev1.ObjectReference{Name: instance.Name, Kind: instance.Kind, Namespace: instance.Namespace}
-			if instance.Spec.OSImageDeploymentType == baremetalv1.OSImageDeploymentTypePassThrough {
-				// PassThrough mode: use container URL directly
-				foundBaremetalHost.Spec.Image = &metal3v1.Image{
-					URL: instance.Spec.OSContainerImageURL,
-				}
-			} else {
-				// SelfExtracting mode: use provision server
-				foundBaremetalHost.Spec.Image = &metal3v1.Image{
-					URL:          provServer.Status.LocalImageURL,
-					Checksum:     provServer.Status.LocalImageChecksumURL,
-					ChecksumType: provServer.Status.OSImageChecksumType,
-				}
-			}
+			foundBaremetalHost.Spec.Image = BaremetalHostImage(instance, provServers, foundBaremetalHost)
 			foundBaremetalHost.Spec.UserData = userDataSecret
 			foundBaremetalHost.Spec.NetworkData = networkDataSecret
 		}
@@ -263,21 +300,319 @@ func BaremetalHostProvision(
 		l.Info("BaremetalHost successfully reconciled", "BMH", foundBaremetalHost.Name, "operation", string(op))
 	}
 
+	if isNewHost && len(sts) > 0 {
+		secretNames := []string{}
+		for _, st := range sts {
+			secretNames = append(secretNames, st.Name)
+		}
+		RecordBaremetalHostEvent(recorder, instance, foundBaremetalHost, corev1.EventTypeNormal,
+			EventReasonCloudInitSecretsCreated,
+			"Generated cloud-init secret(s) %s in namespace %s for host %s",
+			strings.Join(secretNames, ", "), instance.Spec.BmhNamespace, hostName)
+	}
+
+	if startingProvisioning {
+		RecordBaremetalHostEvent(recorder, instance, foundBaremetalHost, corev1.EventTypeNormal,
+			EventReasonBmhProvisioningStarted,
+			"Started provisioning BaremetalHost %s for host %s with image %s",
+			foundBaremetalHost.Name, hostName, foundBaremetalHost.Spec.Image.URL)
+	}
+
+	if bmhStatus.ProvisioningState != baremetalv1.ProvisioningState(metal3v1.StateProvisioned) &&
+		foundBaremetalHost.Status.Provisioning.State == metal3v1.StateProvisioned {
+		RecordBaremetalHostEvent(recorder, instance, foundBaremetalHost, corev1.EventTypeNormal,
+			EventReasonBmhProvisioned,
+			"BaremetalHost %s for host %s provisioned", foundBaremetalHost.Name, hostName)
+	}
+
 	//
 	// Update status with BMH provisioning details
 	//
-	bmhStatus.UserDataSecretName = userDataSecret.Name
-	bmhStatus.NetworkDataSecretName = networkDataSecret.Name
+	if userDataSecret != nil {
+		bmhStatus.UserDataSecretName = userDataSecret.Name
+	}
+	if networkDataSecret != nil {
+		bmhStatus.NetworkDataSecretName = networkDataSecret.Name
+	}
+	if bmhStatus.ProvisioningStartTime == nil {
+		now := metav1.Now()
+		bmhStatus.ProvisioningStartTime = &now
+	}
 	bmhStatus.ProvisioningState = baremetalv1.ProvisioningState(foundBaremetalHost.Status.Provisioning.State)
+	// As far as the set is concerned, an adopted externally provisioned BMH is provisioned
+	if bmhStatus.Adopted && foundBaremetalHost.Status.Provisioning.State == metal3v1.StateExternallyProvisioned {
+		bmhStatus.ProvisioningState = baremetalv1.ProvisioningState(metal3v1.StateProvisioned)
+	}
+	bmhStatus.ProvisioningHistory = recordProvisioningPhase(bmhStatus.ProvisioningHistory, bmhStatus.ProvisioningState)
+	bmhStatus.OperationalStatus = string(foundBaremetalHost.Status.OperationalStatus)
+	bmhStatus.ErrorType = string(foundBaremetalHost.Status.ErrorType)
+	bmhStatus.ErrorMessage = foundBaremetalHost.Status.ErrorMessage
+	_, bmhStatus.AnnotatedForDeletion = foundBaremetalHost.Annotations[HostRemovalAnnotation]
 	instance.Status.BaremetalHosts[hostName] = bmhStatus
 
 	return nil
 }
 
+// BaremetalHostAdopt - Take over an already provisioned BaremetalHost for a host of the set, without
+// touching its image, userData or networkData. From then on BaremetalHostProvision manages it as any other
+func BaremetalHostAdopt(
+	ctx context.Context,
+	helper *helper.Helper,
+	recorder record.EventRecorder,
+	instance *baremetalv1.OpenStackBaremetalSet,
+	bmh string,
+	hostName string,
+) error {
+	l := log.FromContext(ctx)
+
+	foundBaremetalHost := &metal3v1.BareMetalHost{}
+	err := helper.GetClient().Get(ctx, types.NamespacedName{Name: bmh, Namespace: instance.Spec.BmhNamespace}, foundBaremetalHost)
+	if err != nil {
+		return err
+	}
+
+	_, err = controllerutil.CreateOrPatch(ctx, helper.GetClient(), foundBaremetalHost, func() error {
+		labelSelector := labels.GetLabels(instance, labels.GetGroupLabel(baremetalv1.ServiceName), map[string]string{
+			fmt.Sprintf("%s%s", instance.Name, HostnameLabelSelectorSuffix): hostName,
+		})
+		foundBaremetalHost.Labels = util.MergeStringMaps(
+			foundBaremetalHost.GetLabels(),
+			labelSelector,
+		)
+		foundBaremetalHost.Spec.ConsumerRef = &corev1.ObjectReference{Name: instance.Name, Kind: instance.Kind, Namespace: instance.Namespace}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	l.Info("BaremetalHost adopted", "BMH", foundBaremetalHost.Name, "Hostname", hostName)
+	RecordBaremetalHostEvent(recorder, instance, foundBaremetalHost, corev1.EventTypeNormal,
+		EventReasonBmhAdopted,
+		"Adopted BaremetalHost %s in state %s for host %s", foundBaremetalHost.Name,
+		foundBaremetalHost.Status.Provisioning.State, hostName)
+
+	instance.Status.BaremetalHosts[hostName] = baremetalv1.HostStatus{
+		IPStatus: baremetalv1.IPStatus{
+			Hostname:    hostName,
+			BmhRef:      foundBaremetalHost.Name,
+			IPAddresses: baremetalv1.BaremetalHostIPAddresses(instance, hostName),
+		},
+		Adopted: true,
+	}
+
+	return nil
+}
+
+// ProvisionServers - The provision servers of a set, by the CPU arch of its archImages, "" for the default one
+type ProvisionServers map[string]*baremetalv1.OpenStackProvisionServer
+
+// Archs - The CPU archs of the provision servers, sorted
+func (p ProvisionServers) Archs() []string {
+	return slices.Sorted(maps.Keys(p))
+}
+
+// BaremetalHostArch - The CPU arch of a BaremetalHost, "" if it wasn't inspected
+func BaremetalHostArch(bmh *metal3v1.BareMetalHost) string {
+	if bmh.Status.HardwareDetails == nil {
+		return ""
+	}
+	return bmh.Status.HardwareDetails.CPU.Arch
+}
+
+// BaremetalHostImage - The OS image a BaremetalHost of the set gets provisioned with, the one of the archImages
+// for its CPU arch if any
+func BaremetalHostImage(
+	instance *baremetalv1.OpenStackBaremetalSet,
+	provServers ProvisionServers,
+	bmh *metal3v1.BareMetalHost,
+) *metal3v1.Image {
+	arch := BaremetalHostArch(bmh)
+
+	if instance.Spec.OSImageDeploymentType == baremetalv1.OSImageDeploymentTypePassThrough {
+		// PassThrough mode: use container URL directly
+		url := instance.Spec.OSContainerImageURL
+		if archImage, ok := instance.Spec.ArchImages[arch]; ok && archImage.OSContainerImageURL != "" {
+			url = archImage.OSContainerImageURL
+		}
+		return &metal3v1.Image{
+			URL: url,
+		}
+	}
+
+	// SelfExtracting mode: use provision server
+	provServer, ok := provServers[arch]
+	if !ok {
+		provServer = provServers[""]
+	}
+	return &metal3v1.Image{
+		URL:          provServer.Status.LocalImageURL,
+		Checksum:     provServer.Status.LocalImageChecksumURL,
+		ChecksumType: provServer.Status.OSImageChecksumType,
+	}
+}
+
+// BaremetalHostReimage - Have Metal3 deprovision a provisioned BaremetalHost so that it gets provisioned again
+// with the current image. The BMH stays allocated to the host, keeping its labels, consumerRef and cloud-init
+// data, and BaremetalHostProvision sets the new image once Metal3 started deprovisioning it
+func BaremetalHostReimage(
+	ctx context.Context,
+	helper *helper.Helper,
+	recorder record.EventRecorder,
+	instance *baremetalv1.OpenStackBaremetalSet,
+	bmhStatus baremetalv1.HostStatus,
+) error {
+	l := log.FromContext(ctx)
+
+	baremetalHost := &metal3v1.BareMetalHost{}
+	err := helper.GetClient().Get(ctx, types.NamespacedName{Name: bmhStatus.BmhRef, Namespace: instance.Spec.BmhNamespace}, baremetalHost)
+	if err != nil {
+		return err
+	}
+
+	previousImage := ""
+	if baremetalHost.Spec.Image != nil {
+		previousImage = baremetalHost.Spec.Image.URL
+	}
+
+	// Remove the re-image annotation (if any) so that the host is re-imaged only once
+	annotations := baremetalHost.GetObjectMeta().GetAnnotations()
+	delete(annotations, ReimageAnnotation)
+	baremetalHost.GetObjectMeta().SetAnnotations(annotations)
+
+	baremetalHost.Spec.Image = nil
+	// Metal3 does not deprovision externally provisioned BMHs, which adopted ones may be
+	baremetalHost.Spec.ExternallyProvisioned = false
+	err = helper.GetClient().Update(ctx, baremetalHost)
+	if err != nil {
+		return err
+	}
+
+	l.Info("Re-imaging BaremetalHost", "BMH", baremetalHost.Name, "Hostname", bmhStatus.Hostname, "Image", previousImage)
+	RecordBaremetalHostEvent(recorder, instance, baremetalHost, corev1.EventTypeNormal,
+		EventReasonBmhReimageStarted,
+		"Re-imaging BaremetalHost %s of host %s, previously provisioned with image %s",
+		baremetalHost.Name, bmhStatus.Hostname, previousImage)
+
+	// The provisioning timeout applies to the re-provisioning as well
+	now := metav1.Now()
+	bmhStatus.ProvisioningStartTime = &now
+	bmhStatus.ReimagePhase = baremetalv1.ReimagePhaseDeprovisioning
+	instance.Status.BaremetalHosts[bmhStatus.Hostname] = bmhStatus
+
+	return nil
+}
+
+// BaremetalHostQuarantine - Flag a BaremetalHost that failed to provision according to the set's remediation
+// policy, so that it is not picked again once released
+func BaremetalHostQuarantine(
+	ctx context.Context,
+	helper *helper.Helper,
+	recorder record.EventRecorder,
+	instance *baremetalv1.OpenStackBaremetalSet,
+	bmhStatus baremetalv1.HostStatus,
+) error {
+	l := log.FromContext(ctx)
+
+	baremetalHost := &metal3v1.BareMetalHost{}
+	err := helper.GetClient().Get(ctx, types.NamespacedName{Name: bmhStatus.BmhRef, Namespace: instance.Spec.BmhNamespace}, baremetalHost)
+	if err != nil {
+		return err
+	}
+
+	if instance.Spec.Remediation.Quarantine {
+		baremetalHostLabels := baremetalHost.GetObjectMeta().GetLabels()
+		if baremetalHostLabels == nil {
+			baremetalHostLabels = map[string]string{}
+		}
+		baremetalHostLabels[baremetalv1.QuarantineLabel] = instance.Name
+		baremetalHost.GetObjectMeta().SetLabels(baremetalHostLabels)
+	}
+
+	for _, taint := range instance.Spec.Remediation.QuarantineTaints {
+		found := false
+		for _, existing := range baremetalHost.Spec.Taints {
+			if existing.MatchTaint(&taint) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			baremetalHost.Spec.Taints = append(baremetalHost.Spec.Taints, taint)
+		}
+	}
+
+	err = helper.GetClient().Update(ctx, baremetalHost)
+	if err != nil {
+		return err
+	}
+
+	l.Info("BaremetalHost quarantined", "BMH", baremetalHost.Name, "Hostname", bmhStatus.Hostname)
+	RecordBaremetalHostEvent(recorder, instance, baremetalHost, corev1.EventTypeWarning,
+		EventReasonBmhQuarantined,
+		"Quarantined BaremetalHost %s after it failed to provision host %s", baremetalHost.Name, bmhStatus.Hostname)
+
+	return nil
+}
+
+// BaremetalHostProvisioningFailure - Check whether a BaremetalHost that is not yet provisioned has failed, either
+// because Metal3 reported a provisioning error for it or because it exceeded the set's provisioning timeout. Metal3
+// retries on its other errors, e.g. registration or power management ones, so those only fail the host once the
+// timeout expires. Returns the failure description (empty if the host has not failed) and the time left before the
+// timeout expires (zero if there is no timeout or it already expired)
+func BaremetalHostProvisioningFailure(
+	instance *baremetalv1.OpenStackBaremetalSet,
+	bmhStatus baremetalv1.HostStatus,
+	now time.Time,
+) (string, time.Duration) {
+	if bmhStatus.ErrorType == string(metal3v1.ProvisioningError) {
+		return fmt.Sprintf("Metal3 reported %s: %s", bmhStatus.ErrorType, bmhStatus.ErrorMessage), 0
+	}
+
+	if instance.Spec.ProvisioningTimeout == nil || bmhStatus.ProvisioningStartTime == nil {
+		return "", 0
+	}
+
+	remaining := bmhStatus.ProvisioningStartTime.Add(instance.Spec.ProvisioningTimeout.Duration).Sub(now)
+	if remaining <= 0 {
+		return fmt.Sprintf("not provisioned within %s", instance.Spec.ProvisioningTimeout.Duration), 0
+	}
+
+	return "", remaining
+}
+
+// recordProvisioningPhase - Append a new phase to the history if the provisioning state changed,
+// closing the previous phase and keeping at most MaxProvisioningHistory entries
+func recordProvisioningPhase(
+	history []baremetalv1.ProvisioningPhase,
+	state baremetalv1.ProvisioningState,
+) []baremetalv1.ProvisioningPhase {
+	if len(history) > 0 && history[len(history)-1].State == state {
+		return history
+	}
+
+	now := metav1.Now()
+	if len(history) > 0 {
+		history[len(history)-1].EndTime = &now
+	}
+	history = append(history, baremetalv1.ProvisioningPhase{
+		State:     state,
+		StartTime: now,
+	})
+
+	if len(history) > MaxProvisioningHistory {
+		history = history[len(history)-MaxProvisioningHistory:]
+	}
+
+	return history
+}
+
 // BaremetalHostDeprovision - Deprovision a BaremetalHost via Metal3 and return the OSP compute hostname that was deleted
 func BaremetalHostDeprovision(
 	ctx context.Context,
 	helper *helper.Helper,
+	recorder record.EventRecorder,
 	instance *baremetalv1.OpenStackBaremetalSet,
 	bmhStatus baremetalv1.HostStatus,
 ) error {
@@ -291,15 +626,7 @@ func BaremetalHostDeprovision(
 
 	l.Info("Deallocating BaremetalHost", "BMH", baremetalHost.Name)
 
-	// Remove our ownership labels
-	baremetalHostLabels := baremetalHost.GetObjectMeta().GetLabels()
-	labelSelector := labels.GetLabels(instance, labels.GetGroupLabel(baremetalv1.ServiceName), map[string]string{
-		fmt.Sprintf("%s%s", instance.Name, HostnameLabelSelectorSuffix): bmhStatus.Hostname,
-	})
-	for key := range labelSelector {
-		delete(baremetalHostLabels, key)
-	}
-	baremetalHost.GetObjectMeta().SetLabels(baremetalHostLabels)
+	removeOwnershipLabels(instance, baremetalHost, bmhStatus.Hostname)
 
 	// Remove deletion annotation (if any)
 	annotations := baremetalHost.GetObjectMeta().GetAnnotations()
@@ -317,6 +644,9 @@ func BaremetalHostDeprovision(
 	}
 
 	l.Info("BaremetalHost deleted", "BMH", baremetalHost.Name, "Hostname", bmhStatus.Hostname)
+	RecordBaremetalHostEvent(recorder, instance, baremetalHost, corev1.EventTypeNormal,
+		EventReasonBmhDeprovisioned,
+		"Deprovisioned BaremetalHost %s of host %s", baremetalHost.Name, bmhStatus.Hostname)
 
 	// Also remove userdata and networkdata secrets
 	for _, secret := range []string{
@@ -343,6 +673,89 @@ func BaremetalHostDeprovision(
 	return nil
 }
 
+// BaremetalHostRelease - Release a BaremetalHost from the set without deprovisioning it. Only our ownership
+// labels and the consumerRef are removed, the node keeps running with its image, userData and networkData
+func BaremetalHostRelease(
+	ctx context.Context,
+	helper *helper.Helper,
+	recorder record.EventRecorder,
+	instance *baremetalv1.OpenStackBaremetalSet,
+	bmhStatus baremetalv1.HostStatus,
+) error {
+	l := log.FromContext(ctx)
+
+	baremetalHost := &metal3v1.BareMetalHost{}
+	err := helper.GetClient().Get(ctx, types.NamespacedName{Name: bmhStatus.BmhRef, Namespace: instance.Spec.BmhNamespace}, baremetalHost)
+	if err != nil {
+		return err
+	}
+
+	removeOwnershipLabels(instance, baremetalHost, bmhStatus.Hostname)
+	baremetalHost.Spec.ConsumerRef = nil
+	err = helper.GetClient().Update(ctx, baremetalHost)
+	if err != nil {
+		return err
+	}
+
+	// The generated cloud-init secrets are owned by the set, drop that ownership
+	// so that they are not garbage collected along with it
+	for _, secretName := range []string{bmhStatus.UserDataSecretName, bmhStatus.NetworkDataSecretName} {
+		if secretName == "" {
+			continue
+		}
+
+		secret := &corev1.Secret{}
+		err := helper.GetClient().Get(ctx, types.NamespacedName{Name: secretName, Namespace: instance.Spec.BmhNamespace}, secret)
+		if err != nil {
+			if k8s_errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		ownerRefs := []metav1.OwnerReference{}
+		for _, ownerRef := range secret.GetOwnerReferences() {
+			if ownerRef.UID != instance.UID {
+				ownerRefs = append(ownerRefs, ownerRef)
+			}
+		}
+		if len(ownerRefs) == len(secret.GetOwnerReferences()) {
+			continue
+		}
+		secret.SetOwnerReferences(ownerRefs)
+		err = helper.GetClient().Update(ctx, secret)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.Info("BaremetalHost released", "BMH", baremetalHost.Name, "Hostname", bmhStatus.Hostname)
+	RecordBaremetalHostEvent(recorder, instance, baremetalHost, corev1.EventTypeNormal,
+		EventReasonBmhReleased,
+		"Released BaremetalHost %s of host %s without deprovisioning it", baremetalHost.Name, bmhStatus.Hostname)
+
+	// Set status (remove this BaremetalHost entry)
+	delete(instance.Status.BaremetalHosts, bmhStatus.Hostname)
+
+	return nil
+}
+
+// removeOwnershipLabels - Remove the labels tying a BaremetalHost to a host of the set
+func removeOwnershipLabels(
+	instance *baremetalv1.OpenStackBaremetalSet,
+	baremetalHost *metal3v1.BareMetalHost,
+	hostName string,
+) {
+	baremetalHostLabels := baremetalHost.GetObjectMeta().GetLabels()
+	labelSelector := labels.GetLabels(instance, labels.GetGroupLabel(baremetalv1.ServiceName), map[string]string{
+		fmt.Sprintf("%s%s", instance.Name, HostnameLabelSelectorSuffix): hostName,
+	})
+	for key := range labelSelector {
+		delete(baremetalHostLabels, key)
+	}
+	baremetalHost.GetObjectMeta().SetLabels(baremetalHostLabels)
+}
+
 // NodeHostNameIsFQDN Helper to check if a hostname is fqdn
 func hostNameIsFQDN(hostname string) bool {
 	// Regular expression to match a valid FQDN
@@ -1,5 +1,9 @@
 package openstackbaremetalset
 
+import (
+	baremetalv1 "github.com/openstack-k8s-operators/openstack-baremetal-operator/api/v1beta1"
+)
+
 const (
 	// BmhRefInitState - (legacy, currently unused)
 	BmhRefInitState = "unassigned"
@@ -12,8 +16,36 @@ const (
 	// HostnameLabelSelectorSuffix = Suffix used with OSBMS instance name to label BMH as belonging to an entry in OSBMS.Spec.BaremetalHosts
 	HostnameLabelSelectorSuffix = "-osbms-hostname"
 
-	// HostRemovalAnnotation - (legacy, currently unused) Annotation key placed BMH resources to target them for scale-down
-	HostRemovalAnnotation = "baremetal.openstack.org/delete-host"
+	// HostRemovalAnnotation - Annotation key placed on BMH resources to target them for scale-down
+	HostRemovalAnnotation = baremetalv1.HostRemovalAnnotation
+
+	// ReimageAnnotation - Annotation key placed on BMH resources to have their host re-imaged
+	ReimageAnnotation = "baremetal.openstack.org/reimage"
+
+	// MaxProvisioningHistory - Maximum number of provisioning phases kept per host in the OSBMS status
+	MaxProvisioningHistory = 10
+
+	// EventReasonBmhAllocated - Event reason used when a BaremetalHost is selected for a host of the set
+	EventReasonBmhAllocated = "BmhAllocated"
+	// EventReasonBmhAdopted - Event reason used when an already provisioned BaremetalHost is adopted for a host of the set
+	EventReasonBmhAdopted = "BmhAdopted"
+	// EventReasonCloudInitSecretsCreated - Event reason used when the cloud-init secrets of a host are generated
+	EventReasonCloudInitSecretsCreated = "CloudInitSecretsCreated"
+	// EventReasonBmhProvisioningStarted - Event reason used when a BaremetalHost is handed to Metal3 for provisioning
+	EventReasonBmhProvisioningStarted = "BmhProvisioningStarted"
+	// EventReasonBmhProvisioned - Event reason used when a BaremetalHost reaches the provisioned state
+	EventReasonBmhProvisioned = "BmhProvisioned"
+	// EventReasonBmhDeprovisioned - Event reason used when a BaremetalHost is released by the set
+	EventReasonBmhDeprovisioned = "BmhDeprovisioned"
+	// EventReasonBmhReleased - Event reason used when a BaremetalHost is released by the set without being deprovisioned
+	EventReasonBmhReleased = "BmhReleased"
+	// EventReasonBmhQuarantined - Event reason used when a BaremetalHost that failed to provision is quarantined
+	EventReasonBmhQuarantined = "BmhQuarantined"
+
+	// EventReasonBmhReimageStarted - Event reason used when a provisioned BaremetalHost is deprovisioned to be re-imaged
+	EventReasonBmhReimageStarted = "BmhReimageStarted"
+	// EventReasonBmhReimaged - Event reason used when a re-imaged BaremetalHost is provisioned again
+	EventReasonBmhReimaged = "BmhReimaged"
 
 	// MustGatherSecret - Label placed on secrets that are safe to collect with must-gater
 	MustGatherSecret = "baremetal.openstack.org/must-gather-secret"
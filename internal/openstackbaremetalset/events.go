@@ -0,0 +1,22 @@
+package openstackbaremetalset
+
+import (
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	baremetalv1 "github.com/openstack-k8s-operators/openstack-baremetal-operator/api/v1beta1"
+	"k8s.io/client-go/tools/record"
+)
+
+// RecordBaremetalHostEvent - Emit an Event on the OpenStackBaremetalSet and mirror it on the BaremetalHost it concerns,
+// so that the history can be followed from either object
+func RecordBaremetalHostEvent(
+	recorder record.EventRecorder,
+	instance *baremetalv1.OpenStackBaremetalSet,
+	bmh *metal3v1.BareMetalHost,
+	eventType string,
+	reason string,
+	messageFmt string,
+	args ...any,
+) {
+	recorder.Eventf(instance, eventType, reason, messageFmt, args...)
+	recorder.Eventf(bmh, eventType, reason, messageFmt, args...)
+}
@@ -0,0 +1,280 @@
+package openstackbaremetalset
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	baremetalv1 "github.com/openstack-k8s-operators/openstack-baremetal-operator/api/v1beta1"
+)
+
+// networkDataLink - A link of the generated network_data.json, a vif, bond or vlan
+type networkDataLink struct {
+	Name        string
+	Type        string
+	BondLinks   []string
+	BondMode    string
+	BondOptions map[string]string
+	VlanID      int
+	VlanLink    string
+	MTU         int
+}
+
+// networkDataRoute - A route of a network of the generated network_data.json
+type networkDataRoute struct {
+	Network string
+	Netmask string
+	Gateway string
+}
+
+// networkDataNetwork - A network of the generated network_data.json
+type networkDataNetwork struct {
+	ID        string
+	Link      string
+	IPVersion string
+	IP        string
+	Netmask   string
+	Routes    []networkDataRoute
+}
+
+// networkDataLinks - Links of the generated network_data.json by name, keeping their order
+type networkDataLinks struct {
+	links []networkDataLink
+	index map[string]int
+}
+
+// add - Add a link unless there is one of the same name already
+func (l *networkDataLinks) add(link networkDataLink) {
+	if _, ok := l.index[link.Name]; ok {
+		return
+	}
+	l.index[link.Name] = len(l.links)
+	l.links = append(l.links, link)
+}
+
+// raiseMTU - Make sure a link added here has at least the given MTU
+func (l *networkDataLinks) raiseMTU(name string, mtu int) {
+	if i, ok := l.index[name]; ok {
+		l.links[i].MTU = max(l.links[i].MTU, mtu)
+	}
+}
+
+// networkDataForNetworks - The links and networks of the generated network_data.json for the networks of a host
+// besides ctlplane. Links already rendered for ctlplane are reused, with the MTU given by ctlplaneInterfaceMTU
+func networkDataForNetworks(
+	networks []baremetalv1.NetworkSpec,
+	ctlplaneInterface string,
+	ctlplaneLinks []string,
+) ([]networkDataLink, []networkDataNetwork, error) {
+	links := networkDataLinks{index: map[string]int{}}
+	existing := map[string]bool{}
+	for _, link := range ctlplaneLinks {
+		existing[link] = true
+	}
+	addLink := func(link networkDataLink) {
+		if !existing[link.Name] {
+			links.add(link)
+		}
+	}
+
+	dataNetworks := []networkDataNetwork{}
+	for _, network := range networks {
+		iface := network.Interface
+		if iface == "" {
+			iface = ctlplaneInterface
+		}
+
+		if network.Bond != nil {
+			for _, member := range network.Bond.BondInterfaces {
+				addLink(networkDataLink{Name: member, Type: "vif"})
+			}
+			bondMode := network.Bond.BondMode
+			if bondMode == "" {
+				bondMode = "active-backup"
+			}
+			addLink(networkDataLink{
+				Name:        iface,
+				Type:        "bond",
+				BondLinks:   network.Bond.BondInterfaces,
+				BondMode:    bondMode,
+				BondOptions: networkDataBondOptions(network.Bond.BondOptions),
+			})
+		} else {
+			addLink(networkDataLink{Name: iface, Type: "vif"})
+		}
+
+		link := iface
+		if network.Vlan != nil {
+			link = fmt.Sprintf("%s.%d", iface, *network.Vlan)
+			addLink(networkDataLink{
+				Name:     link,
+				Type:     "vlan",
+				VlanID:   *network.Vlan,
+				VlanLink: iface,
+			})
+		}
+
+		// The parent of a VLAN needs at least the MTU of the VLAN
+		if network.MTU != 0 {
+			links.raiseMTU(link, network.MTU)
+			links.raiseMTU(iface, network.MTU)
+		}
+
+		dataNetwork, err := newNetworkDataNetwork(network.Name, link, network.IP, network.Gateway, network.Routes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("network %s: %w", network.Name, err)
+		}
+		dataNetworks = append(dataNetworks, dataNetwork)
+	}
+
+	return links.links, dataNetworks, nil
+}
+
+// ctlplaneInterfaceMTU - The MTU of the ctlplane interface, the vif or bond that carries the ctlplane link:
+// ctlplaneMTU, raised to the MTU of the networks of the host on the same interface, which a VLAN can't exceed
+func ctlplaneInterfaceMTU(
+	networks []baremetalv1.NetworkSpec,
+	ctlplaneInterface string,
+	ctlplaneMTU int,
+) int {
+	mtu := ctlplaneMTU
+	for _, network := range networks {
+		if network.Interface == "" || network.Interface == ctlplaneInterface {
+			mtu = max(mtu, network.MTU)
+		}
+	}
+	return mtu
+}
+
+// networkDataForCtlplane - The networks of the generated network_data.json for the ctlplane addresses of a host,
+// all on the ctlplane link. The first one is identified by the link, the others by the link and their IP version.
+// Each address gets the static routes to destinations of its IP version
+func networkDataForCtlplane(
+	addresses []baremetalv1.CtlplaneAddress,
+	ctlplaneLink string,
+	routes []baremetalv1.Route,
+) ([]networkDataNetwork, error) {
+	dataNetworks := []networkDataNetwork{}
+	for i, address := range addresses {
+		ipAddr, _, err := net.ParseCIDR(address.IP)
+		if err != nil {
+			return nil, err
+		}
+		addressRoutes := []baremetalv1.Route{}
+		for _, route := range routes {
+			destination, _, err := net.ParseCIDR(route.Destination)
+			// Invalid destinations are left for newNetworkDataNetwork to report
+			if err != nil || baremetalv1.IPVersion(destination) == baremetalv1.IPVersion(ipAddr) {
+				addressRoutes = append(addressRoutes, route)
+			}
+		}
+
+		dataNetwork, err := newNetworkDataNetwork(ctlplaneLink, ctlplaneLink, address.IP, address.Gateway, addressRoutes)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			dataNetwork.ID = fmt.Sprintf("%s-%s", ctlplaneLink, dataNetwork.IPVersion)
+		}
+		dataNetworks = append(dataNetworks, dataNetwork)
+	}
+	return dataNetworks, nil
+}
+
+// newNetworkDataNetwork - A network of the generated network_data.json on a link, for an IP in CIDR notation,
+// with a default route through gateway if set and the given static routes
+func newNetworkDataNetwork(
+	id string,
+	link string,
+	cidr string,
+	gateway string,
+	routes []baremetalv1.Route,
+) (networkDataNetwork, error) {
+	ipAddr, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return networkDataNetwork{}, err
+	}
+
+	dataNetwork := networkDataNetwork{
+		ID:        id,
+		Link:      link,
+		IPVersion: baremetalv1.IPVersion(ipAddr),
+		IP:        ipAddr.String(),
+		Netmask:   net.IP(ipNet.Mask).String(),
+		Routes:    []networkDataRoute{},
+	}
+
+	if gateway != "" {
+		dataNetwork.Routes = append(dataNetwork.Routes, defaultRoute(ipAddr, gateway))
+	}
+	for _, route := range routes {
+		_, destination, err := net.ParseCIDR(route.Destination)
+		if err != nil {
+			return networkDataNetwork{}, err
+		}
+		dataNetwork.Routes = append(dataNetwork.Routes, networkDataRoute{
+			Network: destination.IP.String(),
+			Netmask: net.IP(destination.Mask).String(),
+			Gateway: route.NextHop,
+		})
+	}
+
+	return dataNetwork, nil
+}
+
+// networkDataMACAddresses - The MAC addresses of the vif links of the generated network_data.json by link name,
+// taken from the NICs of the BaremetalHost as set by source. ctlplaneVif is the ctlplane link if it is a vif
+func networkDataMACAddresses(
+	source baremetalv1.InterfaceMACAddressSource,
+	bmh *metal3v1.BareMetalHost,
+	vifs []string,
+	ctlplaneVif string,
+) (map[string]string, error) {
+	macAddresses := map[string]string{}
+	if source == "" {
+		return macAddresses, nil
+	}
+	if bmh.Status.HardwareDetails == nil {
+		return nil, fmt.Errorf("BaremetalHost %s has no hardware details to take MAC addresses from", bmh.Name)
+	}
+
+	nics := bmh.Status.HardwareDetails.NIC
+	for _, vif := range vifs {
+		var i int
+		if source == baremetalv1.InterfaceMACAddressesFromPXE && vif == ctlplaneVif {
+			i = slices.IndexFunc(nics, func(nic metal3v1.NIC) bool { return nic.PXE })
+			if i < 0 {
+				return nil, fmt.Errorf("BaremetalHost %s has no PXE NIC for %s", bmh.Name, vif)
+			}
+		} else {
+			i = slices.IndexFunc(nics, func(nic metal3v1.NIC) bool { return nic.Name == vif })
+			if i < 0 {
+				return nil, fmt.Errorf("BaremetalHost %s has no NIC named %s", bmh.Name, vif)
+			}
+		}
+		macAddresses[vif] = nics[i].MAC
+	}
+	return macAddresses, nil
+}
+
+// networkDataBondOptions - The bondOptions of a bond as network_data.json link keys, which carry the bond_ prefix
+func networkDataBondOptions(options map[string]string) map[string]string {
+	if len(options) == 0 {
+		return nil
+	}
+	linkOptions := make(map[string]string, len(options))
+	for key, value := range options {
+		linkOptions[baremetalv1.BondOptionPrefix+strings.TrimPrefix(key, baremetalv1.BondOptionPrefix)] = value
+	}
+	return linkOptions
+}
+
+// defaultRoute - The default route of the IP family of ip through gateway
+func defaultRoute(ip net.IP, gateway string) networkDataRoute {
+	if ip.To4() != nil {
+		return networkDataRoute{Network: "0.0.0.0", Netmask: "0.0.0.0", Gateway: gateway}
+	}
+	return networkDataRoute{Network: "::", Netmask: "::", Gateway: gateway}
+}
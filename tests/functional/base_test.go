@@ -26,6 +26,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // Create OpenstackBaremetalSet in k8s and test that no errors occur
@@ -346,3 +347,16 @@ func ProvisionServerConditionGetter(name types.NamespacedName) condition.Conditi
 	instance := GetProvisionServerDirect(name)
 	return instance.Status.Conditions
 }
+
+// Get the reasons of all Events recorded for the named object of the given kind
+func GetEventReasons(name types.NamespacedName, kind string) []string {
+	events := &corev1.EventList{}
+	Expect(k8sClient.List(ctx, events, client.InNamespace(name.Namespace))).Should(Succeed())
+	reasons := []string{}
+	for _, event := range events.Items {
+		if event.InvolvedObject.Kind == kind && event.InvolvedObject.Name == name.Name {
+			reasons = append(reasons, event.Reason)
+		}
+	}
+	return reasons
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package functional
 
 import (
+	"fmt"
 	"strings"
 
 	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
@@ -30,7 +31,9 @@ import (
 	. "github.com/openstack-k8s-operators/lib-common/modules/common/test/helpers"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 var _ = Describe("BaremetalSet Test", func() {
@@ -359,6 +362,84 @@ var _ = Describe("BaremetalSet Test", func() {
 				g.Expect(bmh.Spec.NetworkData).ToNot(BeNil())
 			}, th.Timeout, th.Interval).Should(Succeed())
 		})
+
+		It("Should record allocation and provisioning Events on the BaremetalSet and the BMH", func() {
+			Eventually(func(g Gomega) {
+				for _, reasons := range [][]string{
+					GetEventReasons(baremetalSetName, "OpenStackBaremetalSet"),
+					GetEventReasons(bmhName, "BareMetalHost"),
+				} {
+					g.Expect(reasons).To(ContainElements(
+						"BmhAllocated",
+						"CloudInitSecretsCreated",
+						"BmhProvisioningStarted",
+					))
+				}
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioned
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(GetEventReasons(baremetalSetName, "OpenStackBaremetalSet")).To(ContainElement("BmhProvisioned"))
+				g.Expect(GetEventReasons(bmhName, "BareMetalHost")).To(ContainElement("BmhProvisioned"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("Should expose host and matching BMH metrics for the BaremetalSet", func() {
+			Eventually(func(g Gomega) {
+				families, err := metrics.Registry.Gather()
+				g.Expect(err).ToNot(HaveOccurred())
+
+				found := map[string]bool{}
+				for _, family := range families {
+					for _, metric := range family.GetMetric() {
+						labels := map[string]string{}
+						for _, label := range metric.GetLabel() {
+							labels[label.GetName()] = label.GetValue()
+						}
+						if labels["namespace"] == namespace && labels["name"] == baremetalSetName.Name {
+							found[family.GetName()] = true
+						}
+					}
+				}
+				g.Expect(found).To(HaveKey("openstack_baremetal_baremetalset_hosts"))
+				g.Expect(found).To(HaveKey("openstack_baremetal_baremetalset_matching_bmhs"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("Should record provisioning history and Metal3 error details in the host status", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				history := baremetalSet.Status.BaremetalHosts["compute-0"].ProvisioningHistory
+				g.Expect(history).To(HaveLen(1))
+				g.Expect(history[0].State).To(Equal(baremetalv1.ProvisioningState(metal3v1.StateAvailable)))
+				g.Expect(history[0].EndTime).To(BeNil())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioning
+				bmh.Status.OperationalStatus = metal3v1.OperationalStatusError
+				bmh.Status.ErrorType = metal3v1.ProvisioningError
+				bmh.Status.ErrorMessage = "image download failed"
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				hostStatus := GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"]
+				g.Expect(hostStatus.ProvisioningHistory).To(HaveLen(2))
+				g.Expect(hostStatus.ProvisioningHistory[0].EndTime).ToNot(BeNil())
+				g.Expect(hostStatus.ProvisioningHistory[1].State).To(Equal(baremetalv1.ProvisioningState(metal3v1.StateProvisioning)))
+				g.Expect(hostStatus.OperationalStatus).To(Equal(string(metal3v1.OperationalStatusError)))
+				g.Expect(hostStatus.ErrorType).To(Equal(string(metal3v1.ProvisioningError)))
+				g.Expect(hostStatus.ErrorMessage).To(Equal("image download failed"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
 	})
 
 	When("BMH provisioned with VLAN configuration", func() {
@@ -933,6 +1014,30 @@ var _ = Describe("BaremetalSet Test", func() {
 				g.Expect(bmh.Spec.Image).To(BeNil())
 			}, "5s", "1s").Should(Succeed())
 		})
+
+		It("Should report free and rejected matching BMHs in the capacity status", func() {
+			Eventually(func(g Gomega) {
+				capacity := GetBaremetalSet(baremetalSetName).Status.Capacity
+				g.Expect(capacity).ToNot(BeNil())
+				g.Expect(capacity.FreeBmhs).To(Equal(1))
+				g.Expect(capacity.Candidates).To(ConsistOf(bmhName.Name))
+				g.Expect(capacity.Rejected).To(BeEmpty())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			// A new BMH that is not yet available must show up as rejected
+			unavailableBmhName := types.NamespacedName{Name: "compute-1", Namespace: namespace}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(unavailableBmhName))
+
+			Eventually(func(g Gomega) {
+				capacity := GetBaremetalSet(baremetalSetName).Status.Capacity
+				g.Expect(capacity).ToNot(BeNil())
+				g.Expect(capacity.FreeBmhs).To(Equal(1))
+				g.Expect(capacity.Rejected).To(ConsistOf(baremetalv1.BmhRejection{
+					Name:    unavailableBmhName.Name,
+					Reasons: []baremetalv1.BmhRejectionReason{baremetalv1.BmhRejectionNotAvailable},
+				}))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
 	})
 
 	When("BMH with invalid IPv6 CIDR format", func() {
@@ -1241,4 +1346,1137 @@ var _ = Describe("BaremetalSet Test", func() {
 			)
 		})
 	})
+
+	When("A BaremetalSet with a provisioning timeout has a host that does not provision in time", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["provisioningTimeout"] = "2s"
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should mark the host as failed and report BmhProvisioningFailed", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].ProvisioningState).To(Equal(baremetalv1.ProvisioningStateFailed))
+				cond := baremetalSet.Status.Conditions.Get(baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition)
+				g.Expect(cond).ToNot(BeNil())
+				g.Expect(cond.Status).To(Equal(corev1.ConditionFalse))
+				g.Expect(cond.Reason).To(Equal(baremetalv1.OpenStackBaremetalSetBmhProvisioningFailedReason))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("Should emit a warning Event for the failed host", func() {
+			Eventually(func(g Gomega) {
+				events := &corev1.EventList{}
+				g.Expect(k8sClient.List(ctx, events, client.InNamespace(namespace))).To(Succeed())
+				found := false
+				for _, event := range events.Items {
+					if event.InvolvedObject.Name == baremetalSetName.Name &&
+						event.Reason == string(baremetalv1.OpenStackBaremetalSetBmhProvisioningFailedReason) {
+						found = true
+						g.Expect(event.Type).To(Equal(corev1.EventTypeWarning))
+						g.Expect(event.Message).To(ContainSubstring("compute-0"))
+					}
+				}
+				g.Expect(found).To(BeTrue())
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet has a host for which Metal3 reports an error", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, PassThroughBaremetalSetSpec(bmhName)))
+
+			Eventually(func(g Gomega) {
+				g.Expect(GetBaremetalSet(baremetalSetName).Status.BaremetalHosts).To(HaveKey("compute-0"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("Should mark the host as failed without waiting for a timeout", func() {
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioning
+				bmh.Status.ErrorType = metal3v1.ProvisioningError
+				bmh.Status.ErrorMessage = "deploy failed"
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].ProvisioningState).To(Equal(baremetalv1.ProvisioningStateFailed))
+				cond := baremetalSet.Status.Conditions.Get(baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition)
+				g.Expect(cond).ToNot(BeNil())
+				g.Expect(cond.Reason).To(Equal(baremetalv1.OpenStackBaremetalSetBmhProvisioningFailedReason))
+				g.Expect(cond.Message).To(ContainSubstring("compute-0"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet has a host for which Metal3 reports an error it retries", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["remediation"] = map[string]any{
+				"replaceFailedHosts": true,
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+
+			Eventually(func(g Gomega) {
+				g.Expect(GetBaremetalSet(baremetalSetName).Status.BaremetalHosts).To(HaveKey("compute-0"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("Should leave the host to Metal3 rather than mark it as failed", func() {
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioning
+				bmh.Status.OperationalStatus = metal3v1.OperationalStatusError
+				bmh.Status.ErrorType = metal3v1.PowerManagementError
+				bmh.Status.ErrorMessage = "failed to power on"
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				hostStatus := GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"]
+				g.Expect(hostStatus.ErrorType).To(Equal(string(metal3v1.PowerManagementError)))
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Consistently(func(g Gomega) {
+				hostStatus := GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"]
+				g.Expect(hostStatus.ProvisioningState).ToNot(Equal(baremetalv1.ProvisioningStateFailed))
+				g.Expect(hostStatus.BmhRef).To(Equal(bmhName.Name))
+				g.Expect(GetBaremetalHost(bmhName).Spec.ConsumerRef).ToNot(BeNil())
+			}, "5s", "1s").Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet with a remediation policy has a host that fails to provision", func() {
+		var spareBmhName types.NamespacedName
+
+		BeforeEach(func() {
+			spareBmhName = types.NamespacedName{
+				Name:      "compute-spare",
+				Namespace: namespace,
+			}
+			for _, name := range []types.NamespacedName{bmhName, spareBmhName} {
+				DeferCleanup(th.DeleteInstance, CreateBaremetalHost(name))
+				Eventually(func(g Gomega) {
+					bmh := GetBaremetalHost(name)
+					bmh.Status.Provisioning.State = metal3v1.StateAvailable
+					g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+				}, th.Timeout, th.Interval).Should(Succeed())
+			}
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["remediation"] = map[string]any{
+				"replaceFailedHosts": true,
+				"quarantine":         true,
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should quarantine the failed BMH and allocate another one to the same hostname and IP", func() {
+			var failedBmh string
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				failedBmh = baremetalSet.Status.BaremetalHosts["compute-0"].BmhRef
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			failedBmhName := types.NamespacedName{Name: failedBmh, Namespace: namespace}
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(failedBmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioning
+				bmh.Status.ErrorType = metal3v1.ProvisioningError
+				bmh.Status.ErrorMessage = "deploy failed"
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(failedBmhName)
+				g.Expect(bmh.Labels).To(HaveKeyWithValue(baremetalv1.QuarantineLabel, baremetalSetName.Name))
+				g.Expect(bmh.Spec.ConsumerRef).To(BeNil())
+
+				hostStatus := GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"]
+				g.Expect(hostStatus.BmhRef).ToNot(Equal(failedBmh))
+				g.Expect(hostStatus.BmhRef).ToNot(BeEmpty())
+				g.Expect(hostStatus.IPAddresses).To(HaveKeyWithValue("ctlplane", "10.0.0.1/24"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet with a remediation policy and no quarantine has a replacement that fails too", func() {
+		var spareBmhNames []types.NamespacedName
+
+		BeforeEach(func() {
+			spareBmhNames = []types.NamespacedName{
+				{Name: "compute-spare-1", Namespace: namespace},
+				{Name: "compute-spare-2", Namespace: namespace},
+			}
+			for _, name := range append([]types.NamespacedName{bmhName}, spareBmhNames...) {
+				DeferCleanup(th.DeleteInstance, CreateBaremetalHost(name))
+				Eventually(func(g Gomega) {
+					bmh := GetBaremetalHost(name)
+					bmh.Status.Provisioning.State = metal3v1.StateAvailable
+					g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+				}, th.Timeout, th.Interval).Should(Succeed())
+			}
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["remediation"] = map[string]any{
+				"replaceFailedHosts": true,
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should not allocate the first failed BMH again", func() {
+			failBmh := func(name types.NamespacedName) {
+				Eventually(func(g Gomega) {
+					bmh := GetBaremetalHost(name)
+					bmh.Status.Provisioning.State = metal3v1.StateProvisioning
+					bmh.Status.ErrorType = metal3v1.ProvisioningError
+					bmh.Status.ErrorMessage = "deploy failed"
+					g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+				}, th.Timeout, th.Interval).Should(Succeed())
+			}
+
+			var firstBmh string
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				firstBmh = baremetalSet.Status.BaremetalHosts["compute-0"].BmhRef
+				g.Expect(firstBmh).ToNot(BeEmpty())
+			}, th.Timeout, th.Interval).Should(Succeed())
+			firstBmhName := types.NamespacedName{Name: firstBmh, Namespace: namespace}
+			failBmh(firstBmhName)
+
+			var secondBmh string
+			Eventually(func(g Gomega) {
+				secondBmh = GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"].BmhRef
+				g.Expect(secondBmh).ToNot(BeEmpty())
+				g.Expect(secondBmh).ToNot(Equal(firstBmh))
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			// The first BMH is back in the free pool, as Metal3 would leave it once deprovisioned
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(firstBmhName)
+				g.Expect(bmh.Annotations).To(HaveKeyWithValue(baremetalv1.FailedBmhAnnotation,
+					fmt.Sprintf("%s/%s", baremetalSetName.Namespace, baremetalSetName.Name)))
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				bmh.Status.ErrorType = ""
+				bmh.Status.ErrorMessage = ""
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+			failBmh(types.NamespacedName{Name: secondBmh, Namespace: namespace})
+
+			Eventually(func(g Gomega) {
+				bmhRef := GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"].BmhRef
+				g.Expect(bmhRef).ToNot(BeEmpty())
+				g.Expect(bmhRef).ToNot(Equal(secondBmh))
+				g.Expect(bmhRef).ToNot(Equal(firstBmh))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet with a remediation policy has a pinned host that fails to provision", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["remediation"] = map[string]any{
+				"replaceFailedHosts": true,
+				"quarantine":         true,
+			}
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"bmhName":    bmhName.Name,
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should keep the pinned BMH and only report the host as failed", func() {
+			Eventually(func(g Gomega) {
+				g.Expect(GetBaremetalSet(baremetalSetName).Status.BaremetalHosts).To(HaveKey("compute-0"))
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioning
+				bmh.Status.ErrorType = metal3v1.ProvisioningError
+				bmh.Status.ErrorMessage = "deploy failed"
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				cond := GetBaremetalSet(baremetalSetName).Status.Conditions.Get(
+					baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition)
+				g.Expect(cond).ToNot(BeNil())
+				g.Expect(cond.Reason).To(Equal(baremetalv1.OpenStackBaremetalSetBmhProvisioningFailedReason))
+				g.Expect(cond.Message).To(ContainSubstring("compute-0"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			bmh := GetBaremetalHost(bmhName)
+			Expect(bmh.Labels).ToNot(HaveKey(baremetalv1.QuarantineLabel))
+			Expect(bmh.Spec.ConsumerRef).ToNot(BeNil())
+			Expect(GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"].BmhRef).To(Equal(bmhName.Name))
+		})
+	})
+
+	When("A BaremetalSet with a rolling reimage strategy gets a new OS image", func() {
+		newImageURL := "quay.io/podified-antelope-centos9/edpm-hardened-uefi@next"
+
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["reimageStrategy"] = map[string]any{
+				"type":           "Rolling",
+				"maxUnavailable": 1,
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				g.Expect(bmh.Spec.ConsumerRef).ToNot(BeNil())
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioned
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			th.ExpectCondition(
+				baremetalSetName,
+				ConditionGetterFunc(BaremetalSetConditionGetter),
+				baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+				corev1.ConditionTrue,
+			)
+		})
+
+		It("Should deprovision and reprovision the BMH with the new image, keeping its cloud-init data", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				baremetalSet.Spec.OSContainerImageURL = newImageURL
+				g.Expect(th.K8sClient.Update(th.Ctx, baremetalSet)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				g.Expect(bmh.Spec.Image).To(BeNil())
+				g.Expect(bmh.Spec.ConsumerRef).ToNot(BeNil())
+				g.Expect(bmh.Spec.UserData).ToNot(BeNil())
+				g.Expect(bmh.Spec.NetworkData).ToNot(BeNil())
+				hostStatus := GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"]
+				g.Expect(hostStatus.ReimagePhase).To(Equal(baremetalv1.ReimagePhaseDeprovisioning))
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateDeprovisioning
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				g.Expect(bmh.Spec.Image).ToNot(BeNil())
+				g.Expect(bmh.Spec.Image.URL).To(Equal(newImageURL))
+				hostStatus := GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"]
+				g.Expect(hostStatus.ReimagePhase).To(Equal(baremetalv1.ReimagePhaseProvisioning))
+				g.Expect(hostStatus.IPAddresses).To(HaveKeyWithValue("ctlplane", "10.0.0.1/24"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioned
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				hostStatus := GetBaremetalSet(baremetalSetName).Status.BaremetalHosts["compute-0"]
+				g.Expect(hostStatus.ReimagePhase).To(BeEmpty())
+				g.Expect(GetEventReasons(baremetalSetName, "OpenStackBaremetalSet")).To(
+					ContainElements("BmhReimageStarted", "BmhReimaged"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet limits how many hosts are provisioned at once", func() {
+		var secondBmhName types.NamespacedName
+
+		BeforeEach(func() {
+			secondBmhName = types.NamespacedName{
+				Name:      "compute-1",
+				Namespace: namespace,
+			}
+			for _, name := range []types.NamespacedName{bmhName, secondBmhName} {
+				DeferCleanup(th.DeleteInstance, CreateBaremetalHost(name))
+				Eventually(func(g Gomega) {
+					bmh := GetBaremetalHost(name)
+					bmh.Status.Provisioning.State = metal3v1.StateAvailable
+					g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+				}, th.Timeout, th.Interval).Should(Succeed())
+			}
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+				},
+				"compute-1": map[string]any{
+					"ctlPlaneIP": "10.0.0.2/24",
+				},
+			}
+			spec["maxConcurrentProvisioning"] = 1
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should only allocate the next host once the previous one is provisioned", func() {
+			var firstBmh string
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveLen(1))
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				firstBmh = baremetalSet.Status.BaremetalHosts["compute-0"].BmhRef
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			th.ExpectConditionWithDetails(
+				baremetalSetName,
+				ConditionGetterFunc(BaremetalSetConditionGetter),
+				baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+				corev1.ConditionFalse,
+				condition.RequestedReason,
+				fmt.Sprintf(baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyWaitingMessage, "compute-1"),
+			)
+
+			Consistently(func(g Gomega) {
+				g.Expect(GetBaremetalSet(baremetalSetName).Status.BaremetalHosts).To(HaveLen(1))
+			}, "3s", "1s").Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(types.NamespacedName{Name: firstBmh, Namespace: namespace})
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioned
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(GetBaremetalSet(baremetalSetName).Status.BaremetalHosts).To(HaveKey("compute-1"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet is paused", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["paused"] = true
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should not hand any host to Metal3 until it is unpaused", func() {
+			th.ExpectConditionWithDetails(
+				baremetalSetName,
+				ConditionGetterFunc(BaremetalSetConditionGetter),
+				baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+				corev1.ConditionFalse,
+				baremetalv1.OpenStackBaremetalSetBmhProvisioningPausedReason,
+				fmt.Sprintf(baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyPausedMessage, "compute-0"),
+			)
+
+			Consistently(func(g Gomega) {
+				g.Expect(GetBaremetalHost(bmhName).Spec.ConsumerRef).To(BeNil())
+			}, "3s", "1s").Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				baremetalSet.Spec.Paused = false
+				g.Expect(th.K8sClient.Update(th.Ctx, baremetalSet)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(GetBaremetalHost(bmhName).Spec.ConsumerRef).ToNot(BeNil())
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet with the Retain deletion policy is deleted", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["deletionPolicy"] = "Retain"
+			CreateBaremetalSet(baremetalSetName, spec)
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				g.Expect(bmh.Spec.ConsumerRef).ToNot(BeNil())
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioned
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("Should release the BMH without deprovisioning it", func() {
+			th.DeleteInstance(GetBaremetalSet(baremetalSetName))
+
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				g.Expect(bmh.Spec.ConsumerRef).To(BeNil())
+				g.Expect(bmh.Labels).ToNot(HaveKey(baremetalSetName.Name + "-osbms-hostname"))
+				g.Expect(bmh.Spec.Online).To(BeTrue())
+				g.Expect(bmh.Spec.Image).ToNot(BeNil())
+				g.Expect(bmh.Spec.UserData).ToNot(BeNil())
+				g.Expect(bmh.Spec.NetworkData).ToNot(BeNil())
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet adopts an already provisioned BMH", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Spec.Image = &metal3v1.Image{URL: "http://example.com/existing.qcow2", Checksum: "abc"}
+				g.Expect(th.K8sClient.Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateProvisioned
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"adoptBmh":   bmhName.Name,
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should take over the BMH without re-provisioning it", func() {
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				g.Expect(bmh.Spec.ConsumerRef).ToNot(BeNil())
+				g.Expect(bmh.Spec.ConsumerRef.Name).To(Equal(baremetalSetName.Name))
+				g.Expect(bmh.Labels).To(HaveKeyWithValue(baremetalSetName.Name+"-osbms-hostname", "compute-0"))
+				g.Expect(bmh.Spec.Image).ToNot(BeNil())
+				g.Expect(bmh.Spec.Image.URL).To(Equal("http://example.com/existing.qcow2"))
+
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				hostStatus := baremetalSet.Status.BaremetalHosts["compute-0"]
+				g.Expect(hostStatus.Adopted).To(BeTrue())
+				g.Expect(hostStatus.BmhRef).To(Equal(bmhName.Name))
+				g.Expect(hostStatus.ProvisioningState).To(Equal(baremetalv1.ProvisioningState(metal3v1.StateProvisioned)))
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			th.ExpectCondition(
+				baremetalSetName,
+				ConditionGetterFunc(BaremetalSetConditionGetter),
+				baremetalv1.OpenStackBaremetalSetBmhProvisioningReadyCondition,
+				corev1.ConditionTrue,
+			)
+		})
+	})
+
+	When("A BaremetalSet selects BMHs with bmhMatchExpressions", func() {
+		var maintenanceBmhName types.NamespacedName
+		var rackBmhName types.NamespacedName
+
+		BeforeEach(func() {
+			maintenanceBmhName = types.NamespacedName{Name: "compute-maintenance", Namespace: namespace}
+			rackBmhName = types.NamespacedName{Name: "compute-rack", Namespace: namespace}
+			bmhs := map[types.NamespacedName]map[string]string{
+				bmhName:            {"rack": "r2"},
+				maintenanceBmhName: {"rack": "r1", "maintenance": "true"},
+				rackBmhName:        {"rack": "r1"},
+			}
+			for name, nodeLabels := range bmhs {
+				DeferCleanup(th.DeleteInstance, CreateBaremetalHostWithNodeLabel(name, nodeLabels))
+				Eventually(func(g Gomega) {
+					bmh := GetBaremetalHost(name)
+					bmh.Status.Provisioning.State = metal3v1.StateAvailable
+					g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+				}, th.Timeout, th.Interval).Should(Succeed())
+			}
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["bmhMatchExpressions"] = []map[string]any{
+				{"key": "maintenance", "operator": "DoesNotExist"},
+			}
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"bmhMatchExpressions": []map[string]any{
+						{"key": "rack", "operator": "In", "values": []string{"r1", "r3"}},
+					},
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should only allocate a BMH satisfying both the set and the host expressions", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].BmhRef).To(Equal(rackBmhName.Name))
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				capacity := GetBaremetalSet(baremetalSetName).Status.Capacity
+				g.Expect(capacity).ToNot(BeNil())
+				g.Expect(capacity.Candidates).To(ConsistOf(bmhName.Name))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet has a topologySpreadConstraint", func() {
+		BeforeEach(func() {
+			bmhs := map[string]string{
+				"compute-r1-a": "r1",
+				"compute-r1-b": "r1",
+				"compute-r2-a": "r2",
+				"compute-r2-b": "r2",
+			}
+			for name, rack := range bmhs {
+				rackBmhName := types.NamespacedName{Name: name, Namespace: namespace}
+				DeferCleanup(th.DeleteInstance, CreateBaremetalHostWithNodeLabel(rackBmhName, map[string]string{"rack": rack}))
+				Eventually(func(g Gomega) {
+					bmh := GetBaremetalHost(rackBmhName)
+					bmh.Status.Provisioning.State = metal3v1.StateAvailable
+					g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+				}, th.Timeout, th.Interval).Should(Succeed())
+			}
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+				},
+				"compute-1": map[string]any{
+					"ctlPlaneIP": "10.0.0.2/24",
+				},
+			}
+			spec["topologySpreadConstraints"] = []map[string]any{
+				{"topologyKey": "rack", "maxSkew": 1},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should allocate the hosts BMHs in different racks", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveLen(2))
+
+				racks := []string{}
+				for _, hostStatus := range baremetalSet.Status.BaremetalHosts {
+					bmh := GetBaremetalHost(types.NamespacedName{Name: hostStatus.BmhRef, Namespace: namespace})
+					racks = append(racks, bmh.Labels["rack"])
+				}
+				g.Expect(racks).To(ConsistOf("r1", "r2"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet with PassThrough mode has archImages", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				bmh.Status.HardwareDetails = &metal3v1.HardwareDetails{
+					CPU: metal3v1.CPU{Arch: "aarch64"},
+				}
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["archImages"] = map[string]any{
+				"aarch64": map[string]any{
+					"osContainerImageUrl": "quay.io/podified-antelope-centos9/edpm-hardened-uefi-aarch64@latest",
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should provision the BMH with the image of its CPU arch", func() {
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				g.Expect(bmh.Spec.ConsumerRef).ToNot(BeNil())
+				g.Expect(bmh.Spec.Image).ToNot(BeNil())
+				g.Expect(bmh.Spec.Image.URL).To(Equal("quay.io/podified-antelope-centos9/edpm-hardened-uefi-aarch64@latest"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet with SelfExtracting mode has archImages", func() {
+		var archProvisionServerName types.NamespacedName
+
+		BeforeEach(func() {
+			archProvisionServerName = types.NamespacedName{
+				Name:      strings.Join([]string{baremetalSetName.Name, "provisionserver", "aarch64"}, "-"),
+				Namespace: namespace,
+			}
+
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := DefaultBaremetalSetSpec(bmhName, false)
+			spec["archImages"] = map[string]any{
+				"aarch64": map[string]any{
+					"osImage":             "edpm-hardened-uefi-aarch64.qcow2",
+					"osContainerImageUrl": "quay.io/podified-antelope-centos9/edpm-hardened-uefi-aarch64@latest",
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should create a provision server for the CPU arch", func() {
+			provServer := GetProvisionServerDirect(archProvisionServerName)
+			Expect(provServer.Spec.OSImage).To(Equal("edpm-hardened-uefi-aarch64.qcow2"))
+			Expect(provServer.Spec.OSContainerImageURL).To(Equal("quay.io/podified-antelope-centos9/edpm-hardened-uefi-aarch64@latest"))
+
+			defaultProvServer := GetProvisionServer(baremetalSetName)
+			Expect(provServer.Spec.Port).ToNot(Equal(defaultProvServer.Spec.Port))
+		})
+
+		It("Should delete the provision server once the CPU arch is removed from archImages", func() {
+			GetProvisionServerDirect(archProvisionServerName)
+
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				baremetalSet.Spec.ArchImages = nil
+				g.Expect(th.K8sClient.Update(th.Ctx, baremetalSet)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				instance := &baremetalv1.OpenStackProvisionServer{}
+				err := k8sClient.Get(ctx, archProvisionServerName, instance)
+				g.Expect(k8s_errors.IsNotFound(err)).To(BeTrue())
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet derives rootDeviceHints from its diskReqs", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				bmh.Status.HardwareDetails = &metal3v1.HardwareDetails{
+					Storage: []metal3v1.Storage{
+						{Name: "/dev/sda", SizeBytes: 480 * 1073741824, Type: metal3v1.SSD, WWN: "0x5000c500a1b2c3d4"},
+						{Name: "/dev/nvme0n1", SizeBytes: 1920 * 1073741824, Type: metal3v1.NVME, WWN: "eui.0025388b91b2c3d4"},
+					},
+				}
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["hardwareReqs"] = map[string]any{
+				"diskReqs": map[string]any{
+					"nvmeReq": map[string]any{"nvme": true},
+				},
+			}
+			spec["rootDeviceHintsFrom"] = "WWN"
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should set the WWN of the matching disk as root device hint", func() {
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				g.Expect(bmh.Spec.ConsumerRef).ToNot(BeNil())
+				g.Expect(bmh.Spec.RootDeviceHints).ToNot(BeNil())
+				g.Expect(bmh.Spec.RootDeviceHints.WWN).To(Equal("eui.0025388b91b2c3d4"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("A BaremetalSet has networks besides ctlplane", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["networks"] = []map[string]any{
+				{"name": "internalapi", "vlan": 20, "mtu": 1500},
+				{"name": "storage", "interface": "eth1", "vlan": 21, "mtu": 9000},
+			}
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"networks": []map[string]any{
+						{"name": "internalapi", "ip": "172.17.0.100/24"},
+						{"name": "storage", "ip": "172.18.0.100/24"},
+						{
+							"name":      "tenant",
+							"interface": "bond1",
+							"bond":      map[string]any{"bondInterfaces": []string{"eth2", "eth3"}},
+							"ip":        "172.19.0.100/24",
+							"gateway":   "172.19.0.1",
+							"routes":    []map[string]any{{"destination": "172.20.0.0/24", "nextHop": "172.19.0.254"}},
+						},
+					},
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should record the IPs of the host on every network", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].IPAddresses).To(Equal(map[string]string{
+					"ctlplane":    "10.0.0.1/24",
+					"internalapi": "172.17.0.100/24",
+					"storage":     "172.18.0.100/24",
+					"tenant":      "172.19.0.100/24",
+				}))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("Should render the links and networks in networkdata", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName).ToNot(BeEmpty())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			baremetalSet := GetBaremetalSet(baremetalSetName)
+			networkDataSecret := th.GetSecret(types.NamespacedName{
+				Name:      baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName,
+				Namespace: bmhName.Namespace,
+			})
+			networkData := string(networkDataSecret.Data["networkData"])
+			Expect(networkData).To(ContainSubstring("- name: eth0.20\n  id: eth0.20\n  type: vlan\n  vlan_id: 20\n  vlan_link: eth0\n  vlan_mac_address: null\n  mtu: 1500\n"))
+			Expect(networkData).To(ContainSubstring("- name: eth1\n  id: eth1\n  type: vif\n  mtu: 9000\n"))
+			Expect(networkData).To(ContainSubstring("- name: bond1\n  id: bond1\n  type: bond\n"))
+			Expect(networkData).To(ContainSubstring("- link: eth0.20\n  id: internalapi\n  network_id: internalapi\n  type: ipv4\n  ip_address: 172.17.0.100\n"))
+			Expect(networkData).To(ContainSubstring("- link: eth1.21\n  id: storage\n"))
+			Expect(networkData).To(ContainSubstring("  - network: \"172.20.0.0\"\n    netmask: \"255.255.255.0\"\n    gateway: 172.19.0.254\n"))
+		})
+	})
+
+	When("A BaremetalSet has a network VLAN with a bigger MTU than ctlplane on the ctlplane interface", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["ctlplaneMtu"] = 1500
+			spec["ctlplaneVlan"] = 10
+			spec["networks"] = []map[string]any{
+				{"name": "storage", "vlan": 21, "mtu": 9000},
+			}
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"networks": []map[string]any{
+						{"name": "storage", "ip": "172.18.0.100/24"},
+					},
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should raise the MTU of the ctlplane interface only", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName).ToNot(BeEmpty())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			baremetalSet := GetBaremetalSet(baremetalSetName)
+			networkDataSecret := th.GetSecret(types.NamespacedName{
+				Name:      baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName,
+				Namespace: bmhName.Namespace,
+			})
+			networkData := string(networkDataSecret.Data["networkData"])
+			Expect(networkData).To(ContainSubstring("- name: eth0\n  id: eth0\n  type: vif\n  mtu: 9000\n"))
+			Expect(networkData).To(ContainSubstring("  vlan_id: 10\n  vlan_link: eth0\n  vlan_mac_address: null\n  mtu: 1500\n"))
+			Expect(networkData).To(ContainSubstring("  vlan_id: 21\n  vlan_link: eth0\n  vlan_mac_address: null\n  mtu: 9000\n"))
+		})
+	})
+
+	When("A BaremetalSet has dual-stack hosts", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["ctlplaneGateway"] = "10.0.0.254"
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIPs": []map[string]any{
+						{"ip": "10.0.0.1/24"},
+						{"ip": "fd00:1::10/64", "gateway": "fd00:1::1"},
+					},
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should record both ctlplane addresses", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].IPAddresses).To(Equal(map[string]string{
+					"ctlplane":      "10.0.0.1/24",
+					"ctlplane-ipv6": "fd00:1::10/64",
+				}))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("Should render both ctlplane networks on the ctlplane link", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName).ToNot(BeEmpty())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			baremetalSet := GetBaremetalSet(baremetalSetName)
+			networkDataSecret := th.GetSecret(types.NamespacedName{
+				Name:      baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName,
+				Namespace: bmhName.Namespace,
+			})
+			networkData := string(networkDataSecret.Data["networkData"])
+			Expect(networkData).To(ContainSubstring("- link: eth0\n  id: eth0\n  network_id: eth0\n  type: ipv4\n  ip_address: 10.0.0.1\n"))
+			Expect(networkData).To(ContainSubstring("gateway: 10.0.0.254"))
+			Expect(networkData).To(ContainSubstring("- link: eth0\n  id: eth0-ipv6\n  network_id: eth0-ipv6\n  type: ipv6\n  ip_address: fd00:1::10\n"))
+			Expect(networkData).To(ContainSubstring("gateway: fd00:1::1"))
+		})
+	})
+
+	When("A BaremetalSet has ctlplane routes and MTU", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["ctlplaneVlan"] = 100
+			spec["ctlplaneMtu"] = 1500
+			spec["ctlplaneRoutes"] = []map[string]any{
+				{"destination": "10.0.1.0/24", "nextHop": "10.0.0.254"},
+				{"destination": "fd00:2::/64", "nextHop": "fd00:1::1"},
+			}
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIPs": []map[string]any{
+						{"ip": "10.0.0.1/24"},
+						{"ip": "fd00:1::10/64"},
+					},
+					"ctlplaneMtu": 9000,
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should render the routes and MTU of the host", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName).ToNot(BeEmpty())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			baremetalSet := GetBaremetalSet(baremetalSetName)
+			networkDataSecret := th.GetSecret(types.NamespacedName{
+				Name:      baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName,
+				Namespace: bmhName.Namespace,
+			})
+			networkData := string(networkDataSecret.Data["networkData"])
+			Expect(networkData).To(ContainSubstring("- name: eth0\n  id: eth0\n  type: vif\n  mtu: 9000\n"))
+			Expect(networkData).To(ContainSubstring("  vlan_mac_address: null\n  mtu: 9000\n"))
+			Expect(networkData).To(ContainSubstring("  - network: \"10.0.1.0\"\n    netmask: \"255.255.255.0\"\n    gateway: 10.0.0.254\n"))
+			Expect(networkData).To(ContainSubstring("  - network: \"fd00:2::\"\n    netmask: \"ffff:ffff:ffff:ffff::\"\n    gateway: fd00:1::1\n"))
+		})
+	})
+
+	When("A host of a BaremetalSet has its own ctlplane bond", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["ctlplaneInterface"] = "bond0"
+			spec["ctlplaneBond"] = map[string]any{
+				"bondInterfaces": []string{"eno1", "eno2"},
+				"bondMode":       "active-backup",
+			}
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"ctlplaneBond": map[string]any{
+						"bondInterfaces": []string{"ens1f0", "ens1f1"},
+						"bondMode":       "802.3ad",
+						"bondOptions":    map[string]string{"lacp_rate": "fast"},
+					},
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+		})
+
+		It("Should render the bond of the host", func() {
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName).ToNot(BeEmpty())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			baremetalSet := GetBaremetalSet(baremetalSetName)
+			networkDataSecret := th.GetSecret(types.NamespacedName{
+				Name:      baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName,
+				Namespace: bmhName.Namespace,
+			})
+			networkData := string(networkDataSecret.Data["networkData"])
+			Expect(networkData).To(ContainSubstring("  bond_links:\n    - ens1f0\n    - ens1f1\n  bond_mode: 802.3ad\n  bond_lacp_rate: fast\n"))
+			Expect(networkData).NotTo(ContainSubstring("eno1"))
+		})
+	})
+
+	When("A BaremetalSet takes the MAC addresses of interfaces from the hardware details", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				bmh.Status.HardwareDetails = &metal3v1.HardwareDetails{
+					NIC: []metal3v1.NIC{
+						{Name: "enp1s0", MAC: "52:54:00:00:00:01", PXE: true},
+						{Name: "eth1", MAC: "52:54:00:00:00:02"},
+					},
+				}
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(deploymentSecretName))
+		})
+
+		It("Should give the ctlplane interface the MAC address of the PXE NIC", func() {
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["interfaceMacAddressesFrom"] = "PXE"
+			spec["networks"] = []map[string]any{
+				{"name": "storage", "interface": "eth1"},
+			}
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"networks": []map[string]any{
+						{"name": "storage", "ip": "172.18.0.100/24"},
+					},
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName).ToNot(BeEmpty())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			baremetalSet := GetBaremetalSet(baremetalSetName)
+			networkDataSecret := th.GetSecret(types.NamespacedName{
+				Name:      baremetalSet.Status.BaremetalHosts["compute-0"].NetworkDataSecretName,
+				Namespace: bmhName.Namespace,
+			})
+			networkData := string(networkDataSecret.Data["networkData"])
+			Expect(networkData).To(ContainSubstring("- name: eth0\n  id: eth0\n  type: vif\n  ethernet_mac_address: \"52:54:00:00:00:01\"\n"))
+			Expect(networkData).To(ContainSubstring("- name: eth1\n  id: eth1\n  type: vif\n  ethernet_mac_address: \"52:54:00:00:00:02\"\n"))
+		})
+
+		It("Should report an error condition for an interface without a NIC", func() {
+			spec := PassThroughBaremetalSetSpec(bmhName)
+			spec["interfaceMacAddressesFrom"] = "Name"
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+
+			th.ExpectCondition(
+				baremetalSetName,
+				ConditionGetterFunc(BaremetalSetConditionGetter),
+				condition.ReadyCondition,
+				corev1.ConditionFalse,
+			)
+		})
+	})
 })
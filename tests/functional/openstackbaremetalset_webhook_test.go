@@ -2,6 +2,7 @@ package functional
 
 import (
 	"errors"
+	"fmt"
 
 	metal3v1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	. "github.com/onsi/ginkgo/v2" //revive:disable:dot-imports
@@ -251,4 +252,312 @@ var _ = Describe("OpenStackBaremetalSet Webhook", func() {
 
 	})
 
+	When("When removing hosts from a BaremetalSet with the RequireAnnotation scale-down policy", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(types.NamespacedName{Name: "mysecret", Namespace: namespace}))
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["scaleDownPolicy"] = "RequireAnnotation"
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+
+			Eventually(func(g Gomega) {
+				g.Expect(GetBaremetalSet(baremetalSetName).Status.BaremetalHosts).To(HaveKey("compute-0"))
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("It should fail if the BMH is not annotated for deletion", func() {
+			baremetalSet := GetBaremetalSet(baremetalSetName)
+			baremetalSet.Spec.BaremetalHosts = map[string]baremetalv1.InstanceSpec{}
+			err := th.K8sClient.Update(th.Ctx, baremetalSet)
+			Expect(err).Should(HaveOccurred())
+			var statusError *k8s_errors.StatusError
+			Expect(errors.As(err, &statusError)).To(BeTrue())
+			Expect(statusError.ErrStatus.Message).To(
+				ContainSubstring(
+					"are not annotated with baremetal.openstack.org/delete-host"),
+			)
+		})
+
+		It("It should pass and deprovision the BMH once it is annotated for deletion", func() {
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Annotations[baremetalv1.HostRemovalAnnotation] = ""
+				g.Expect(th.K8sClient.Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				baremetalSet.Spec.BaremetalHosts = map[string]baremetalv1.InstanceSpec{}
+				g.Expect(th.K8sClient.Update(th.Ctx, baremetalSet)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+
+			Eventually(func(g Gomega) {
+				g.Expect(GetBaremetalHost(bmhName).Spec.ConsumerRef).To(BeNil())
+				g.Expect(GetBaremetalSet(baremetalSetName).Status.BaremetalHosts).To(BeEmpty())
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("When pinning hosts of a BaremetalSet to BMHs with bmhName", func() {
+		BeforeEach(func() {
+			for _, name := range []types.NamespacedName{bmhName, bmhName1} {
+				DeferCleanup(th.DeleteInstance, CreateBaremetalHost(name))
+				Eventually(func(g Gomega) {
+					bmh := GetBaremetalHost(name)
+					bmh.Status.Provisioning.State = metal3v1.StateAvailable
+					g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+				}, th.Timeout, th.Interval).Should(Succeed())
+			}
+		})
+
+		It("It should fail if the pinned BMH does not exist", func() {
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"bmhName":    "missing-bmh",
+				},
+			}
+			object := DefaultBaremetalSetTemplate(baremetalSetName, spec)
+			unstructuredObj := &unstructured.Unstructured{Object: object}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).Should(HaveOccurred())
+			var statusError *k8s_errors.StatusError
+			Expect(errors.As(err, &statusError)).To(BeTrue())
+			Expect(statusError.ErrStatus.Message).To(
+				ContainSubstring("BaremetalHost missing-bmh pinned by compute-0 not found"),
+			)
+		})
+
+		It("It should fail if the BMH is pinned by another BaremetalSet", func() {
+			otherSetName := types.NamespacedName{Name: "other-baremetalset", Namespace: namespace}
+			otherSpec := PassThroughBaremetalSetSpec(otherSetName)
+			otherSpec["paused"] = true
+			otherSpec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"bmhName":    bmhName1.Name,
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(otherSetName, otherSpec))
+
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.2/24",
+					"bmhName":    bmhName1.Name,
+				},
+			}
+			object := DefaultBaremetalSetTemplate(baremetalSetName, spec)
+			unstructuredObj := &unstructured.Unstructured{Object: object}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).Should(HaveOccurred())
+			var statusError *k8s_errors.StatusError
+			Expect(errors.As(err, &statusError)).To(BeTrue())
+			Expect(statusError.ErrStatus.Message).To(
+				ContainSubstring("is already pinned by host compute-0 of OpenStackBaremetalSet"),
+			)
+		})
+
+		It("It should pass and allocate the pinned BMH", func() {
+			DeferCleanup(th.DeleteInstance, CreateSSHSecret(types.NamespacedName{Name: "mysecret", Namespace: namespace}))
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"bmhName":    bmhName1.Name,
+				},
+			}
+			DeferCleanup(th.DeleteInstance, CreateBaremetalSet(baremetalSetName, spec))
+
+			Eventually(func(g Gomega) {
+				baremetalSet := GetBaremetalSet(baremetalSetName)
+				g.Expect(baremetalSet.Status.BaremetalHosts).To(HaveKey("compute-0"))
+				g.Expect(baremetalSet.Status.BaremetalHosts["compute-0"].BmhRef).To(Equal(bmhName1.Name))
+				g.Expect(GetBaremetalHost(bmhName).Spec.ConsumerRef).To(BeNil())
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+	})
+
+	When("When creating a BaremetalSet with bmhMatchExpressions", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("It should fail if an expression is invalid", func() {
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["bmhMatchExpressions"] = []map[string]any{
+				{"key": "rack", "operator": "Exists", "values": []string{"r1"}},
+			}
+			object := DefaultBaremetalSetTemplate(baremetalSetName, spec)
+			unstructuredObj := &unstructured.Unstructured{Object: object}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).Should(HaveOccurred())
+			var statusError *k8s_errors.StatusError
+			Expect(errors.As(err, &statusError)).To(BeTrue())
+			Expect(statusError.ErrStatus.Message).To(
+				ContainSubstring("invalid BaremetalHost label selector"),
+			)
+		})
+
+		It("It should fail if no BMH satisfies the expressions", func() {
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["bmhMatchExpressions"] = []map[string]any{
+				{"key": "app", "operator": "NotIn", "values": []string{"openstack"}},
+			}
+			object := DefaultBaremetalSetTemplate(baremetalSetName, spec)
+			unstructuredObj := &unstructured.Unstructured{Object: object}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).Should(HaveOccurred())
+			var statusError *k8s_errors.StatusError
+			Expect(errors.As(err, &statusError)).To(BeTrue())
+			Expect(statusError.ErrStatus.Message).To(
+				ContainSubstring("app notin (openstack)"),
+			)
+		})
+	})
+
+	When("When creating a BaremetalSet with a topologySpreadConstraint", func() {
+		BeforeEach(func() {
+			bmhs := map[string]string{
+				"compute-r1-a": "r1",
+				"compute-r1-b": "r1",
+				"compute-r1-c": "r1",
+				"compute-r2-a": "r2",
+			}
+			for name, rack := range bmhs {
+				rackBmhName := types.NamespacedName{Name: name, Namespace: namespace}
+				DeferCleanup(th.DeleteInstance, CreateBaremetalHostWithNodeLabel(rackBmhName, map[string]string{"rack": rack}))
+				Eventually(func(g Gomega) {
+					bmh := GetBaremetalHost(rackBmhName)
+					bmh.Status.Provisioning.State = metal3v1.StateAvailable
+					g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+				}, th.Timeout, th.Interval).Should(Succeed())
+			}
+		})
+
+		It("It should fail if the hosts can't be spread within maxSkew", func() {
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			baremetalHosts := map[string]any{}
+			for i := range 4 {
+				baremetalHosts[fmt.Sprintf("compute-%d", i)] = map[string]any{
+					"ctlPlaneIP": fmt.Sprintf("10.0.0.%d/24", i+1),
+				}
+			}
+			spec["baremetalHosts"] = baremetalHosts
+			spec["topologySpreadConstraints"] = []map[string]any{
+				{"topologyKey": "rack", "maxSkew": 1},
+			}
+			object := DefaultBaremetalSetTemplate(baremetalSetName, spec)
+			unstructuredObj := &unstructured.Unstructured{Object: object}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).Should(HaveOccurred())
+			var statusError *k8s_errors.StatusError
+			Expect(errors.As(err, &statusError)).To(BeTrue())
+			Expect(statusError.ErrStatus.Message).To(
+				ContainSubstring("unable to satisfy topologySpreadConstraints for 4 new hosts " +
+					"(topologyKey rack, maxSkew 1, hosts/free BaremetalHosts per domain: r1=0/3, r2=0/1)"),
+			)
+		})
+
+		It("It should pass if the hosts can be spread within maxSkew", func() {
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{"ctlPlaneIP": "10.0.0.1/24"},
+				"compute-1": map[string]any{"ctlPlaneIP": "10.0.0.2/24"},
+				"compute-2": map[string]any{"ctlPlaneIP": "10.0.0.3/24"},
+			}
+			spec["topologySpreadConstraints"] = []map[string]any{
+				{"topologyKey": "rack", "maxSkew": 1},
+			}
+			object := DefaultBaremetalSetTemplate(baremetalSetName, spec)
+			unstructuredObj := &unstructured.Unstructured{Object: object}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	When("When creating a BaremetalSet with ctlplane bonds", func() {
+		BeforeEach(func() {
+			DeferCleanup(th.DeleteInstance, CreateBaremetalHost(bmhName))
+			Eventually(func(g Gomega) {
+				bmh := GetBaremetalHost(bmhName)
+				bmh.Status.Provisioning.State = metal3v1.StateAvailable
+				g.Expect(th.K8sClient.Status().Update(th.Ctx, bmh)).To(Succeed())
+			}, th.Timeout, th.Interval).Should(Succeed())
+		})
+
+		It("It should fail if the bond mode is not a kernel bonding mode", func() {
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["ctlplaneInterface"] = "bond0"
+			spec["ctlplaneBond"] = map[string]any{
+				"bondInterfaces": []string{"eno1", "eno2"},
+				"bondMode":       "lacp",
+			}
+			object := DefaultBaremetalSetTemplate(baremetalSetName, spec)
+			unstructuredObj := &unstructured.Unstructured{Object: object}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).Should(HaveOccurred())
+			var statusError *k8s_errors.StatusError
+			Expect(errors.As(err, &statusError)).To(BeTrue())
+			Expect(statusError.ErrStatus.Message).To(ContainSubstring("ctlplaneBond: invalid bondMode \"lacp\""))
+		})
+
+		It("It should fail if a host bond has an invalid option", func() {
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["ctlplaneInterface"] = "bond0"
+			spec["baremetalHosts"] = map[string]any{
+				"compute-0": map[string]any{
+					"ctlPlaneIP": "10.0.0.1/24",
+					"ctlplaneBond": map[string]any{
+						"bondInterfaces": []string{"ens1f0", "ens1f1"},
+						"bondMode":       "802.3ad",
+						"bondOptions":    map[string]string{"lacp_rate": "fast", "bond_speed": "25000"},
+					},
+				},
+			}
+			object := DefaultBaremetalSetTemplate(baremetalSetName, spec)
+			unstructuredObj := &unstructured.Unstructured{Object: object}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).Should(HaveOccurred())
+			var statusError *k8s_errors.StatusError
+			Expect(errors.As(err, &statusError)).To(BeTrue())
+			Expect(statusError.ErrStatus.Message).To(
+				ContainSubstring("host compute-0: ctlplaneBond: invalid bondOptions key \"bond_speed\""))
+		})
+
+		It("It should pass with valid bond modes and options", func() {
+			spec := PassThroughBaremetalSetSpec(baremetalSetName)
+			spec["ctlplaneInterface"] = "bond0"
+			spec["ctlplaneBond"] = map[string]any{
+				"bondInterfaces": []string{"eno1", "eno2"},
+				"bondMode":       "802.3ad",
+				"bondOptions":    map[string]string{"bond_miimon": "100", "xmit_hash_policy": "layer3+4"},
+			}
+			object := DefaultBaremetalSetTemplate(baremetalSetName, spec)
+			unstructuredObj := &unstructured.Unstructured{Object: object}
+			_, err := controllerutil.CreateOrPatch(
+				th.Ctx, th.K8sClient, unstructuredObj, func() error { return nil })
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
 })